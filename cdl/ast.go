@@ -0,0 +1,615 @@
+package cdl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/parro-it/ncdf/types"
+)
+
+// Comment is a `//` comment of a CDL source.
+type Comment struct {
+	Pos CodePosition
+	// Text is the text following `//`
+	Text string
+}
+
+// NodeInfo contains the position and
+// the comments of a node of a Document.
+type NodeInfo struct {
+	Pos CodePosition
+	// Doc contains the comments on the lines before the node
+	Doc []*Comment
+	// Line is the comment that follows the node on its line
+	Line *Comment
+	// BlankBefore is set when an empty line precedes the node
+	BlankBefore bool
+}
+
+// Info returns the position and the comments of the node.
+func (n *NodeInfo) Info() *NodeInfo {
+	return n
+}
+
+// Node is a node of a Document.
+type Node interface {
+	Info() *NodeInfo
+}
+
+// Document is the syntax tree of a CDL file. Unlike
+// types.File, it keeps comments and positions of the
+// source, and values as they are written.
+type Document struct {
+	NodeInfo
+	Name     string
+	Sections []*Section
+	// Trailing contains the comments before the closing `}`
+	Trailing []*Comment
+	// Footer contains the comments after the closing `}`
+	Footer []*Comment
+}
+
+// Section is a dimensions, variables or data section.
+type Section struct {
+	NodeInfo
+	// Kind is one of TkDimensions, TkVariables and TkData
+	Kind  TokenType
+	Stmts []Node
+}
+
+// DimDecl declares one or more dimensions,
+// separated by commas.
+type DimDecl struct {
+	NodeInfo
+	Specs []*DimSpec
+}
+
+// DimSpec is a dimension declared by a DimDecl.
+type DimSpec struct {
+	Pos  CodePosition
+	Name string
+	// Len is the length as written, or UNLIMITED
+	Len string
+}
+
+// VarDecl declares one or more variables
+// of type Type, separated by commas.
+type VarDecl struct {
+	NodeInfo
+	Type  string
+	Specs []*VarSpec
+}
+
+// VarSpec is a variable declared by a VarDecl.
+type VarSpec struct {
+	Pos  CodePosition
+	Name string
+	Dims []*Ident
+}
+
+// Ident is a name used in a node, like
+// the dimensions of a variable.
+type Ident struct {
+	Pos  CodePosition
+	Name string
+}
+
+// AttrDecl defines an attribute of variable Var,
+// or a global attribute when Var is empty.
+type AttrDecl struct {
+	NodeInfo
+	// Type is the type prefix of the definition, if any
+	Type   string
+	Var    string
+	Name   string
+	Values []*Literal
+}
+
+// DataStmt assigns values to variable Var in the data section.
+type DataStmt struct {
+	NodeInfo
+	Var    string
+	Values []*Literal
+}
+
+// Literal is a value of an attribute or of a variable.
+type Literal struct {
+	NodeInfo
+	// Kind is TkInt, TkDec, TkStr or, for the `_`
+	// placeholder of fill values, TkName
+	Kind TokenType
+	// Text is the number as written, or the
+	// unescaped string
+	Text string
+	// LineBreak is set when the literal
+	// starts a new line in the source
+	LineBreak bool
+	// Trailing contains the comments on the lines
+	// after the last literal of a list, before its `;`
+	Trailing []*Comment
+
+	// num and numType are the value and the
+	// suffix type of numbers, see Token
+	num     float64
+	numType types.Type
+}
+
+// token returns the token of literal l.
+func (l *Literal) token() Token {
+	return Token{Pos: l.Pos, Type: l.Kind, Text: l.Text, NumVal: l.num, NumType: l.numType}
+}
+
+// ParseDocument parses the CDL source read from r in a
+// Document. name is the name of the file reported in
+// diagnostics. Parsing continues after errors, that are
+// all returned as Diagnostics.
+func ParseDocument(name string, r io.Reader) (*Document, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l := NewLexer(bytes.NewReader(src))
+	l.Comments = true
+	p := docParser{src: l.Next, file: name, source: string(src)}
+	doc := p.parse()
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// docParser parses the grammar of CDL. It builds a
+// Document or, when lower is set, passes each section
+// and statement to lower as soon as it's parsed.
+type docParser struct {
+	// src returns the tokens of the source
+	src    func() (Token, error)
+	file   string
+	source string
+	// lower, when set, receives sections when they start
+	// and their statements, that are not added to the
+	// Document. The errors it returns are reported.
+	lower func(n Node) error
+
+	diags  Diagnostics
+	lexErr error
+
+	// tok is the current token and prev the one
+	// before it: both are never comments.
+	tok  Token
+	prev Token
+	// pending contains the comments read
+	// before tok and not yet assigned to a node
+	pending []*Comment
+	// lastRow is the last row of the previous node
+	lastRow uint
+}
+
+// parse parses the whole source. Errors that
+// stop it are reported, and the Document parsed
+// up to them is returned.
+func (p *docParser) parse() (doc *Document) {
+	doc = new(Document)
+	defer func() {
+		if e := recover(); e != nil {
+			p.report(e)
+		}
+	}()
+	p.parseDocument(doc)
+	return doc
+}
+
+// err returns the diagnostics reported, if any. When the
+// lexer failed, errors found at the end of the tokens are
+// caused by the missing tokens, and are dropped.
+func (p *docParser) err() error {
+	if p.lexErr != nil {
+		var d *Diagnostic
+		if !errors.As(p.lexErr, &d) {
+			d = &Diagnostic{Msg: p.lexErr.Error()}
+		}
+		var diags Diagnostics
+		for _, pd := range p.diags {
+			if !pd.atEnd && pd.Pos.Idx < d.Pos.Idx {
+				diags = append(diags, pd)
+			}
+		}
+		d.File = p.file
+		d.Snippet = snippet(p.source, d.Pos)
+		p.diags = append(diags, d)
+	}
+	if len(p.diags) > 0 {
+		return p.diags
+	}
+	return nil
+}
+
+// next moves to the next token that is not
+// a comment, collecting comments in pending.
+// After an error of the lexer, the tokens end.
+func (p *docParser) next() {
+	p.prev = p.tok
+	for {
+		tk, err := p.src()
+		if err != nil {
+			if p.lexErr == nil {
+				p.lexErr = err
+			}
+			tk = Token{}
+		}
+		if tk.Type != TkComment {
+			p.tok = tk
+			return
+		}
+		p.pending = append(p.pending, &Comment{Pos: tk.Pos, Text: tk.Text})
+	}
+}
+
+func (p *docParser) expected(what string) {
+	panic(&Diagnostic{Msg: what + " expected", Expected: what})
+}
+
+func (p *docParser) errorf(format string, args ...interface{}) {
+	panic(&Diagnostic{Msg: fmt.Sprintf(format, args...)})
+}
+
+// expect fails if the current token is not of type t.
+func (p *docParser) expect(t TokenType, what string) {
+	if p.tok.Type != t {
+		p.expected(what)
+	}
+}
+
+// report records the error e, recovered from a panic or
+// returned by lower, as a diagnostic. Errors without a
+// position are found at the current token.
+func (p *docParser) report(e interface{}) {
+	d, ok := e.(*Diagnostic)
+	if !ok {
+		d = &Diagnostic{Msg: fmt.Sprint(e)}
+	}
+	d.File = p.file
+	if d.Pos.Row == 0 {
+		locate(d, p.tok, p.prev)
+	}
+	d.Snippet = snippet(p.source, d.Pos)
+	p.diags = append(p.diags, d)
+}
+
+// statement calls parse to parse a statement that starts
+// at the current token. When parse fails, the error is
+// reported and tokens are skipped up to the end of the
+// statement or of the section, so that parsing can continue.
+func (p *docParser) statement(parse func()) {
+	defer func() {
+		if e := recover(); e != nil {
+			p.report(e)
+			p.skipStatement()
+		}
+	}()
+	parse()
+}
+
+func (p *docParser) skipStatement() {
+	for {
+		switch p.tok.Type {
+		case TkEmpty, TkCurClose, TkDimensions, TkVariables, TkData:
+			return
+		case TkSemicolon:
+			p.next()
+			return
+		}
+		p.next()
+	}
+}
+
+// emit passes n to lower, reporting its error.
+func (p *docParser) emit(n Node) {
+	if err := p.lower(n); err != nil {
+		p.report(err)
+	}
+}
+
+// takeDoc removes and returns the pending comments.
+func (p *docParser) takeDoc() []*Comment {
+	doc := p.pending
+	p.pending = nil
+	return doc
+}
+
+// takeLine removes and returns the pending
+// comment that starts on row, if any.
+func (p *docParser) takeLine(row uint) *Comment {
+	if len(p.pending) == 0 || p.pending[0].Pos.Start.Row != row {
+		return nil
+	}
+	c := p.pending[0]
+	p.pending = p.pending[1:]
+	return c
+}
+
+// startNode starts node n at the current token,
+// assigning it the pending comments.
+func (p *docParser) startNode(n *NodeInfo) {
+	first := p.tok.Pos.Start.Row
+	if len(p.pending) > 0 {
+		first = p.pending[0].Pos.Start.Row
+	}
+	n.BlankBefore = p.lastRow > 0 && first > p.lastRow+1
+	n.Doc = p.takeDoc()
+	n.Pos.Start = p.tok.Pos.Start
+}
+
+// endNode ends node n at the current token, then moves
+// to the next one. Comments found inside the node and
+// not attached to one of its literals are added to its
+// doc comments.
+func (p *docParser) endNode(n *NodeInfo) {
+	n.Pos.End = p.tok.Pos.End
+	n.Doc = append(n.Doc, p.takeDoc()...)
+	row := p.tok.Pos.End.Row
+	p.next()
+	n.Line = p.takeLine(row)
+	p.lastRow = row
+}
+
+func (p *docParser) parseDocument(doc *Document) {
+	p.next()
+	p.startNode(&doc.NodeInfo)
+	p.expect(TkNetCdf, "`netcdf`")
+	p.next()
+	p.expect(TkName, "dataset name")
+	doc.Name = p.tok.Text
+	p.next()
+	p.expect(TkCurOpen, "`{`")
+	row := p.tok.Pos.End.Row
+	p.next()
+	doc.Line = p.takeLine(row)
+	p.lastRow = row
+
+	for p.tok.Type == TkDimensions || p.tok.Type == TkVariables || p.tok.Type == TkData {
+		doc.Sections = append(doc.Sections, p.parseSection())
+	}
+
+	p.expect(TkCurClose, "`}`")
+	doc.Trailing = p.takeDoc()
+	doc.Pos.End = p.tok.Pos.End
+	p.next()
+	if p.tok.Type != TkEmpty {
+		p.errorf("unexpected %v", p.tok)
+	}
+	doc.Footer = p.takeDoc()
+}
+
+func (p *docParser) parseSection() *Section {
+	s := &Section{Kind: p.tok.Type}
+	p.startNode(&s.NodeInfo)
+	directive := p.tok.Text
+	p.next()
+	if p.tok.Type == TkColon {
+		p.endNode(&s.NodeInfo)
+	} else {
+		p.report(&Diagnostic{Msg: fmt.Sprintf("`:` is required after a `%s` directive", directive)})
+		s.Pos.End = p.prev.Pos.End
+	}
+	if p.lower != nil {
+		p.emit(s)
+	}
+
+	for {
+		switch p.tok.Type {
+		case TkDimensions, TkVariables, TkData, TkCurClose, TkEmpty:
+			return s
+		}
+		var stmt Node
+		p.statement(func() {
+			switch s.Kind {
+			case TkDimensions:
+				stmt = p.parseDimDecl()
+			case TkVariables:
+				stmt = p.parseDecl()
+			default:
+				stmt = p.parseDataStmt()
+			}
+		})
+		if stmt == nil {
+			continue
+		}
+		if p.lower != nil {
+			p.emit(stmt)
+		} else {
+			s.Stmts = append(s.Stmts, stmt)
+		}
+	}
+}
+
+func (p *docParser) parseDimDecl() *DimDecl {
+	d := new(DimDecl)
+	p.startNode(&d.NodeInfo)
+	for {
+		p.expect(TkName, "dimension name")
+		spec := &DimSpec{Pos: p.tok.Pos, Name: p.tok.Text}
+		p.next()
+		p.expect(TkEqual, "`=`")
+		p.next()
+		if p.tok.Type == TkName && strings.EqualFold(p.tok.Text, "unlimited") {
+			spec.Len = "UNLIMITED"
+		} else if p.tok.Type == TkInt {
+			spec.Len = p.tok.Text
+		} else {
+			p.expected("dimension length")
+		}
+		spec.Pos.End = p.tok.Pos.End
+		d.Specs = append(d.Specs, spec)
+		p.next()
+		if p.tok.Type != TkComma {
+			break
+		}
+		p.next()
+	}
+	p.expect(TkSemicolon, "`,` or `;`")
+	p.endNode(&d.NodeInfo)
+	return d
+}
+
+// parseDecl parses the declaration of a variable
+// or the definition of an attribute.
+func (p *docParser) parseDecl() Node {
+	var info NodeInfo
+	p.startNode(&info)
+
+	typeName := ""
+	if p.tok.Type == TkVarType {
+		typeName = p.tok.Text
+		p.next()
+	}
+	varName := ""
+	namePos := p.tok.Pos
+	if p.tok.Type == TkName {
+		varName = p.tok.Text
+		p.next()
+	}
+
+	if typeName != "" && varName != "" && p.tok.Type != TkColon {
+		v := &VarDecl{NodeInfo: info, Type: typeName}
+		v.Specs = append(v.Specs, p.parseVarSpec(varName, namePos))
+		for p.tok.Type == TkComma {
+			p.next()
+			p.expect(TkName, "variable name")
+			name, pos := p.tok.Text, p.tok.Pos
+			p.next()
+			v.Specs = append(v.Specs, p.parseVarSpec(name, pos))
+		}
+		p.expect(TkSemicolon, "`,` or `;`")
+		p.endNode(&v.NodeInfo)
+		return v
+	}
+
+	if p.tok.Type != TkColon {
+		if typeName != "" && varName == "" {
+			p.expected("variable name")
+		}
+		p.expected("`:`")
+	}
+	a := &AttrDecl{NodeInfo: info, Type: typeName, Var: varName}
+	p.next()
+	p.expect(TkName, "attribute name")
+	a.Name = p.tok.Text
+	p.next()
+	p.expect(TkEqual, "`=`")
+	p.next()
+	// attributes with a type can have no values
+	if typeName == "" || p.tok.Type != TkSemicolon {
+		a.Values = p.parseValues("attribute value", false)
+	}
+	p.endNode(&a.NodeInfo)
+	return a
+}
+
+// parseVarSpec parses the declaration of variable name,
+// found at pos, starting from the token that follows the
+// name: its dimension list, if any.
+func (p *docParser) parseVarSpec(name string, pos CodePosition) *VarSpec {
+	v := &VarSpec{Pos: pos, Name: name}
+	switch p.tok.Type {
+	case TkParOpen:
+		for {
+			p.next()
+			p.expect(TkName, "dimension name")
+			v.Dims = append(v.Dims, &Ident{Pos: p.tok.Pos, Name: p.tok.Text})
+			p.next()
+			if p.tok.Type == TkParClose {
+				break
+			}
+			p.expect(TkComma, "`,` or `)`")
+		}
+		v.Pos.End = p.tok.Pos.End
+		p.next()
+	case TkComma, TkSemicolon:
+	default:
+		p.expected("dimension list")
+	}
+	return v
+}
+
+func (p *docParser) parseDataStmt() *DataStmt {
+	d := new(DataStmt)
+	p.startNode(&d.NodeInfo)
+	p.expect(TkName, "variable name")
+	d.Var = p.tok.Text
+	p.next()
+	p.expect(TkEqual, "`=`")
+	p.next()
+	d.Values = p.parseValues("data value", true)
+	p.endNode(&d.NodeInfo)
+	return d
+}
+
+// parseValues parses a comma separated list of literals,
+// described by what, up to the closing `;`. fill allows
+// the `_` placeholder. Comments are attached to the
+// nearest literal: a comment following a literal or its
+// comma on the same line is its line comment, comments
+// on the lines before a literal are its doc comments,
+// and those after the last literal are its trailing
+// comments.
+func (p *docParser) parseValues(what string, fill bool) []*Literal {
+	var values []*Literal
+	// literals are allocated in blocks,
+	// since data sections can be large
+	var block []Literal
+	for {
+		if len(block) == cap(block) {
+			block = make([]Literal, 0, 256)
+		}
+		block = append(block, Literal{
+			Kind:      p.tok.Type,
+			Text:      p.tok.Text,
+			LineBreak: p.tok.Pos.Start.Row > p.prev.Pos.End.Row,
+			num:       p.tok.NumVal,
+			numType:   p.tok.NumType,
+		})
+		lit := &block[len(block)-1]
+		switch {
+		case p.tok.Type == TkInt, p.tok.Type == TkDec, p.tok.Type == TkStr:
+		case fill && p.tok.Type == TkName && p.tok.Text == "_":
+		default:
+			p.expected(what)
+		}
+		lit.Doc = p.takeDoc()
+		lit.Pos = p.tok.Pos
+		values = append(values, lit)
+
+		p.next()
+		lit.Line = p.takeLine(lit.Pos.End.Row)
+		if p.tok.Type == TkSemicolon {
+			lit.Trailing = p.takeDoc()
+			return values
+		}
+		p.expect(TkComma, "`,` or `;`")
+		row := p.tok.Pos.End.Row
+		p.next()
+		if lit.Line == nil {
+			lit.Line = p.takeLine(row)
+		}
+	}
+}
+
+// locate sets the position of d and the text found there
+// from last, the token where the error was found, or from
+// prev, the token before it, at the end of the tokens.
+func locate(d *Diagnostic, last, prev Token) {
+	d.Found = last.Text
+	d.Pos = last.Pos.Start
+	if last.Type == TkEmpty {
+		d.atEnd = true
+		d.Pos = prev.Pos.End
+		if prev.Type != TkEmpty {
+			d.Pos.Col++
+			d.Pos.Idx++
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package cdl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic describes an error found
+// at a position of a CDL source.
+type Diagnostic struct {
+	// File is the name of the source file, if known
+	File string
+	// Pos is the position of the error
+	Pos CodePoint
+	// Msg describes the error
+	Msg string
+	// Expected describes what was expected at Pos, if known
+	Expected string
+	// Found is the text found at Pos
+	Found string
+	// Snippet contains the line of source at Pos,
+	// followed by a line with a caret under Pos.
+	// It's empty when the source is unknown.
+	Snippet string
+
+	// atEnd is set when the error is found
+	// at the end of the tokens
+	atEnd bool
+}
+
+// Error returns the message of the diagnostic.
+func (d *Diagnostic) Error() string {
+	return d.Msg
+}
+
+// String returns the diagnostic in the
+// file:line:col: message form, followed
+// by the source snippet.
+func (d *Diagnostic) String() string {
+	var res strings.Builder
+	if d.File != "" {
+		res.WriteString(d.File + ":")
+	}
+	fmt.Fprintf(&res, "%d:%d: %s", d.Pos.Row, d.Pos.Col, d.Msg)
+	if d.Expected != "" {
+		fmt.Fprintf(&res, " (found %s)", d.found())
+	}
+	if d.Snippet != "" {
+		res.WriteString("\n" + d.Snippet)
+	}
+	return res.String()
+}
+
+func (d *Diagnostic) found() string {
+	if d.Found == "" {
+		return "end of file"
+	}
+	return "`" + d.Found + "`"
+}
+
+// Diagnostics is the error returned by Parse,
+// containing all errors found in a CDL source.
+type Diagnostics []*Diagnostic
+
+func (ds Diagnostics) Error() string {
+	msgs := make([]string, len(ds))
+	for i, d := range ds {
+		msgs[i] = d.Msg
+	}
+	return "Parse failed: " + strings.Join(msgs, "; ")
+}
+
+// String returns all diagnostics in the form
+// returned by Diagnostic.String, one per line.
+func (ds Diagnostics) String() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// snippet returns the line of source containing
+// pos, followed by a line with a caret under pos.
+func snippet(source string, pos CodePoint) string {
+	if source == "" || pos.Row == 0 || int(pos.Idx) > len(source) {
+		return ""
+	}
+	start := strings.LastIndexByte(source[:pos.Idx], '\n') + 1
+	end := strings.IndexByte(source[pos.Idx:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += int(pos.Idx)
+	}
+	line := source[start:end]
+
+	var caret strings.Builder
+	for _, c := range source[start:pos.Idx] {
+		if c == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+	return line + "\n" + caret.String()
+}
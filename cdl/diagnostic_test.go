@@ -0,0 +1,134 @@
+package cdl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics(t *testing.T) {
+	code := `netcdf test {
+dimensions:
+	x = 2 ;
+	y = ;
+variables:
+	float temp(x, z) ;
+	temp:units = "K" ;
+	int count(x) ;
+data:
+	count = 1 2 ;
+}`
+	f, err := ParseFile("test.cdl", strings.NewReader(code))
+	assert.Nil(t, f)
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags))
+	require.Len(t, diags, 4)
+
+	assert.Equal(t, &Diagnostic{
+		File:     "test.cdl",
+		Pos:      CodePoint{Col: 6, Row: 4, Idx: 40},
+		Msg:      "dimension length expected",
+		Expected: "dimension length",
+		Found:    ";",
+		Snippet:  "\ty = ;\n\t    ^",
+	}, diags[0])
+	assert.Equal(t, "unknown dimension name `z`", diags[1].Msg)
+	assert.Equal(t, CodePoint{Col: 16, Row: 6, Idx: 68}, diags[1].Pos)
+	// temp is not declared, because of its error
+	assert.Equal(t, "unknown variable `temp`", diags[2].Msg)
+	assert.Equal(t, "`,` or `;` expected", diags[3].Msg)
+	assert.Equal(t, "2", diags[3].Found)
+
+	assert.Equal(t, "test.cdl:10:12: `,` or `;` expected (found `2`)\n\tcount = 1 2 ;\n\t          ^", diags[3].String())
+	assert.Equal(t, "Parse failed: dimension length expected; unknown dimension name `z`; "+
+		"unknown variable `temp`; `,` or `;` expected", err.Error())
+}
+
+func TestDiagnosticsExpected(t *testing.T) {
+	cases := map[string]string{
+		"netcdf x {variables: :a 1;}":                          "`=`",
+		"netcdf x {variables: :a = dimensions;}":               "attribute value",
+		"netcdf x {dimensions: a = 1; variables: int v(a a);}": "`,` or `)`",
+		"x {}":     "`netcdf`",
+		"netcdf {": "dataset name",
+		"netcdf x": "`{`",
+	}
+	for code, expected := range cases {
+		_, err := ParseFile("test.cdl", strings.NewReader(code))
+		var diags Diagnostics
+		require.True(t, errors.As(err, &diags), code)
+		assert.Equal(t, expected, diags[0].Expected, code)
+		assert.Equal(t, expected+" expected", diags[0].Msg, code)
+
+		_, err = ParseDocument("test.cdl", strings.NewReader(code))
+		require.True(t, errors.As(err, &diags), code)
+		assert.Equal(t, expected, diags[0].Expected, code)
+	}
+}
+
+func TestDiagnosticsAtEnd(t *testing.T) {
+	_, err := ParseFile("test.cdl", strings.NewReader("netcdf test {\ndimensions:\n\tx = 2"))
+	require.Error(t, err)
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags))
+	require.Len(t, diags, 2)
+	assert.Equal(t, "test.cdl:3:7: `,` or `;` expected (found end of file)\n\tx = 2\n\t     ^", diags[0].String())
+	assert.Equal(t, "`}` expected", diags[1].Msg)
+}
+
+func TestTokenizerDiagnostics(t *testing.T) {
+	_, err := ParseFile("test.cdl", strings.NewReader("netcdf test {\ndimensions:\n\tx = 2 ;\n\ty = 1.2.3 ;\n}"))
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags))
+	require.Len(t, diags, 1)
+	assert.Equal(t, "test.cdl:4:9: unexpected dot\n\ty = 1.2.3 ;\n\t       ^", diags[0].String())
+}
+
+func TestUnexpectedCharacter(t *testing.T) {
+	_, err := ParseFile("test.cdl", strings.NewReader("netcdf test {\nvariables:\n\t:x = 1 $ ;\n}"))
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags))
+	require.Len(t, diags, 1)
+	assert.Equal(t, "test.cdl:3:9: unexpected character `$`\n\t:x = 1 $ ;\n\t       ^", diags[0].String())
+
+	_, err = ParseDocument("test.cdl", strings.NewReader("netcdf test {\nvariables:\n\t:x = 1 $ ;\n}"))
+	require.True(t, errors.As(err, &diags))
+	assert.Equal(t, "unexpected character `$`", diags[0].Msg)
+}
+
+func TestDiagnosticsAtNodes(t *testing.T) {
+	code := "netcdf test {\ndimensions:\n\tx = 2 ;\nvariables:\n\tbyte v(x) ;\ndata:\n\tv = 1, 300 ;\n}"
+	_, err := ParseFile("test.cdl", strings.NewReader(code))
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags))
+	require.Len(t, diags, 1)
+	assert.Equal(t, "test.cdl:7:9: invalid data for variable `v`: Value 300 out of range of type NC_BYTE\n\tv = 1, 300 ;\n\t       ^", diags[0].String())
+
+	// documents report all errors of the grammar too
+	_, err = ParseDocument("test.cdl", strings.NewReader("netcdf test {\ndimensions:\n\tx = ;\n\ty = 2 ;\n\tz 3 ;\n}"))
+	require.True(t, errors.As(err, &diags))
+	require.Len(t, diags, 2)
+	assert.Equal(t, "dimension length expected", diags[0].Msg)
+	assert.Equal(t, "`=` expected", diags[1].Msg)
+}
+
+func TestFillValueOutOfRange(t *testing.T) {
+	code := "netcdf test {\ndimensions:\n\tx = 2 ;\nvariables:\n\tbyte v(x) ;\n\t\tv:_FillValue = 300 ;\n}"
+	_, err := ParseFile("test.cdl", strings.NewReader(code))
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags))
+	require.Len(t, diags, 1)
+	assert.Equal(t, "test.cdl:6:18: invalid value for attribute `_FillValue`: Value 300 out of range of type NC_BYTE\n\t\tv:_FillValue = 300 ;\n\t\t               ^", diags[0].String())
+}
+
+func TestTooManyValues(t *testing.T) {
+	code := "netcdf test {\ndimensions:\n\tx = 2 ;\nvariables:\n\tint v(x) ;\ndata:\n\tv = 1, 2,\n\t\t3, 4 ;\n}"
+	_, err := ParseFile("test.cdl", strings.NewReader(code))
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags))
+	require.Len(t, diags, 1)
+	assert.Equal(t, "test.cdl:8:3: too many values for variable `v`, that has 2\n\t\t3, 4 ;\n\t\t^", diags[0].String())
+}
@@ -0,0 +1,289 @@
+package cdl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/parro-it/ncdf/cf"
+	"github.com/parro-it/ncdf/types"
+)
+
+// DumpOptions configures the output of Dump.
+type DumpOptions struct {
+	// Name is the name of the dataset in the output
+	Name string
+	// HeaderOnly omits the data section
+	HeaderOnly bool
+	// Coords prints data of coordinate variables only
+	Coords bool
+	// Vars, if not empty, lists the only
+	// variables whose data is printed
+	Vars []string
+	// Special prints virtual attributes like _Format
+	Special bool
+	// Times prints values of CF time
+	// variables as ISO dates
+	Times bool
+	// MaxLineLen is the length after which lines of
+	// data are wrapped, 80 when zero
+	MaxLineLen int
+}
+
+// Dump writes the header of f and the values
+// of its variables read from fd in CDL, using
+// the same layout as the ncdump utility. Like
+// FormatFile, it reads values a chunk at a time.
+func Dump(w io.Writer, f *types.File, fd io.ReadSeeker, opts DumpOptions) error {
+	if opts.MaxLineLen == 0 {
+		opts.MaxLineLen = 80
+	}
+	for _, name := range opts.Vars {
+		if !f.Vars.Has(name) {
+			return fmt.Errorf("Unknown variable `%s`", name)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fm := &formatter{
+		w:         bw,
+		opts:      FormatOptions{Name: opts.Name, Indent: "\t", Format: opts.Special},
+		precision: dumpPrecision,
+	}
+	fm.header(f)
+
+	if !opts.HeaderOnly {
+		var vars []types.Var
+		for _, v := range f.Vars.Values() {
+			if opts.selects(v) {
+				vars = append(vars, v)
+			}
+		}
+		if len(vars) > 0 {
+			fm.put("data:\n")
+		}
+		d := &dumper{fm: fm, max: opts.MaxLineLen}
+		for _, v := range vars {
+			if err := d.data(f, v, fd, opts); err != nil {
+				return err
+			}
+		}
+	}
+
+	fm.put("}\n")
+	return bw.Flush()
+}
+
+// dumpPrecision returns the number of significant digits
+// of floating point values of type t printed by ncdump.
+func dumpPrecision(t types.Type) int {
+	if t == types.Float {
+		return 7
+	}
+	return 15
+}
+
+// selects returns whether data of variable v is printed.
+func (opts DumpOptions) selects(v types.Var) bool {
+	if len(opts.Vars) > 0 {
+		for _, name := range opts.Vars {
+			if name == v.Name {
+				return true
+			}
+		}
+		return false
+	}
+	if opts.Coords {
+		// a coordinate variable has a single
+		// dimension, with the same name
+		return len(v.Dimensions) == 1 && v.Dimensions[0].Name == v.Name
+	}
+	return true
+}
+
+// FormatName returns the value of the _Format
+// virtual attribute for files of version ver.
+func FormatName(ver types.Version) string {
+	switch ver.OrDefault()[3] {
+	case 1:
+		return "classic"
+	case 5:
+		return "cdf5"
+	}
+	return "64-bit offset"
+}
+
+// ParseFormatName returns the version of files
+// whose _Format virtual attribute is name.
+func ParseFormatName(name string) (types.Version, error) {
+	switch name {
+	case "classic":
+		return types.CDF1, nil
+	case "64-bit offset":
+		return types.CDF2, nil
+	case "cdf5":
+		return types.CDF5, nil
+	}
+	return types.Version{}, fmt.Errorf("Unknown format `%s`", name)
+}
+
+// dumper writes the data section of Dump,
+// wrapping lines longer than max.
+type dumper struct {
+	fm  *formatter
+	col int
+	max int
+}
+
+func (d *dumper) put(s string) {
+	d.fm.put(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		d.col = len(s) - i - 1
+	} else {
+		d.col += len(s)
+	}
+}
+
+// lput writes s, wrapping the line
+// first if s doesn't fit in it.
+func (d *dumper) lput(s string) {
+	if len(s)+d.col > d.max && len(s) > 2 {
+		d.put("\n    ")
+	}
+	d.put(s)
+}
+
+// data writes the values of variable v, read from fd
+// a chunk at a time. Values are printed one row, that is
+// one index of the last dimension, at a time: strings of
+// char variables are a row each.
+func (d *dumper) data(f *types.File, v types.Var, fd io.ReadSeeker, opts DumpOptions) error {
+	rowLen := int64(1)
+	if n := len(v.Dimensions); n > 0 && v.Type != types.Char {
+		rowLen = max(1, f.WholeSlab(v).Count[n-1])
+	}
+
+	fillStr := ""
+	if v.Type != types.Char {
+		fill, err := v.FillValue()
+		if err != nil {
+			return err
+		}
+		fillStr = formatValues(fill, dumpPrecision(v.Type))[0]
+	}
+	var units cf.Units
+	times := false
+	if opts.Times && v.Type != types.Char {
+		var err error
+		units, err = cf.UnitsOf(v)
+		times = err == nil
+	}
+
+	d.put("\n " + EscapeName(v.Name) + " =")
+	if len(v.Dimensions) > 1 {
+		d.put("\n  ")
+	} else {
+		d.put(" ")
+	}
+
+	var written int64
+	err := eachChunk(f, v, fd, func(values types.Value) error {
+		var strs []string
+		var numbers []float64
+		if v.Type == types.Char {
+			strs = formatText(v, values.Interface().([]byte))
+		} else {
+			strs = formatValues(values, dumpPrecision(v.Type))
+			if times {
+				numbers = values.Float64s()
+			}
+		}
+		for i, s := range strs {
+			if written > 0 && written%rowLen == 0 {
+				d.put(",\n  ")
+			}
+			if v.Type != types.Char && s == fillStr {
+				s = "_"
+			} else if times {
+				if date, err := units.Date(numbers[i]); err == nil {
+					s = quote(isoDate(date))
+				}
+			}
+			written++
+			if written%rowLen != 0 {
+				s += ", "
+			}
+			d.lput(s)
+		}
+		return d.fm.w.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	d.put(" ;\n")
+	return nil
+}
+
+// DumpAttrValue formats the values of an attribute
+// as printed by ncdump: type suffixes are added to
+// values of types other than int and double.
+func DumpAttrValue(val types.Value) string {
+	return formatAttr(val, dumpPrecision(val.Type()))
+}
+
+// isoDate formats d omitting trailing zero time fields,
+// as ncdump -t does.
+func isoDate(d cf.Date) string {
+	s := fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+	switch {
+	case d.Nanosecond != 0:
+		return d.String()
+	case d.Second != 0:
+		return s + fmt.Sprintf(" %02d:%02d:%02d", d.Hour, d.Minute, d.Second)
+	case d.Minute != 0:
+		return s + fmt.Sprintf(" %02d:%02d", d.Hour, d.Minute)
+	case d.Hour != 0:
+		return s + fmt.Sprintf(" %02d", d.Hour)
+	}
+	return s
+}
+
+// quote returns s as a CDL string literal. Control
+// characters and bytes that are not part of a valid
+// UTF-8 sequence are escaped.
+func quote(s string) string {
+	var res strings.Builder
+	res.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			res.WriteString(`\"`)
+		case '\\':
+			res.WriteString(`\\`)
+		case '\n':
+			res.WriteString(`\n`)
+		case '\t':
+			res.WriteString(`\t`)
+		case '\r':
+			res.WriteString(`\r`)
+		case 0:
+			res.WriteString(`\0`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&res, "\\%03o", c)
+			} else if c < utf8.RuneSelf {
+				res.WriteByte(c)
+			} else if r, size := utf8.DecodeRuneInString(s[i:]); r == utf8.RuneError && size == 1 {
+				// strings are read as UTF-8: other bytes are escaped
+				fmt.Fprintf(&res, "\\x%02x", c)
+			} else {
+				res.WriteString(s[i : i+size])
+				i += size - 1
+			}
+		}
+	}
+	res.WriteByte('"')
+	return res.String()
+}
@@ -0,0 +1,189 @@
+package cdl
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/parro-it/ncdf/types"
+	"github.com/parro-it/ncdf/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dumpFile(t *testing.T) (*types.File, *bytes.Reader) {
+	dims := []types.Dimension{{Name: "time", Len: 0}, {Name: "x", Len: 3}, {Name: "len", Len: 4}}
+	f := (&types.File{
+		Version:    types.CDF1,
+		Dimensions: dims,
+		Attrs: types.Attrs{
+			{Name: "title", Val: types.Text("a \"test\"\n")},
+		}.Map(),
+		Vars: types.Vars{
+			{Name: "x", Type: types.Short, Dimensions: []*types.Dimension{&dims[1]}, Attrs: types.Attrs{
+				{Name: "valid_range", Val: types.Shorts(0, 10)},
+				{Name: "scale", Val: types.Floats(0.5)},
+			}.Map()},
+			{Name: "names", Type: types.Char, Dimensions: []*types.Dimension{&dims[1], &dims[2]}},
+			{Name: "scalar", Type: types.Double, Attrs: types.Attrs{
+				{Name: "offset", Val: types.Doubles(0, 273.15)},
+			}.Map()},
+			{Name: "time", Type: types.Double, Dimensions: []*types.Dimension{&dims[0]}, Attrs: types.Attrs{
+				{Name: "units", Val: types.Text("hours since 2000-01-01")},
+			}.Map()},
+			{Name: "temp", Type: types.Float, Dimensions: []*types.Dimension{&dims[0], &dims[1]}},
+		}.Map(),
+	}).ComputeSizes()
+
+	var buf buffer
+	require.NoError(t, write.Header(f, &buf))
+	require.NoError(t, write.Fill(f, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("x"), []int16{1, 2, 3}, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("names"), []byte("ab\x00\x00cd\x00\x00efgh"), &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("scalar"), []float64{1.5}, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("time"), []float64{0, 6.5}, &buf))
+	require.NoError(t, write.Slab(f, f.Vars.Get("temp"), types.Slab{
+		Start: []int64{0, 0},
+		Count: []int64{2, 2},
+	}, []float32{0.1, 1e10, -2, 3}, &buf))
+	return f, bytes.NewReader(buf.Bytes())
+}
+
+const dumpHeader = `netcdf test {
+dimensions:
+	time = UNLIMITED ; // (2 currently)
+	x = 3 ;
+	len = 4 ;
+variables:
+	short x(x) ;
+		x:valid_range = 0s, 10s ;
+		x:scale = 0.5f ;
+	char names(x, len) ;
+	double scalar ;
+		scalar:offset = 0., 273.15 ;
+	double time(time) ;
+		time:units = "hours since 2000-01-01" ;
+	float temp(time, x) ;
+
+// global attributes:
+		:title = "a \"test\"\n" ;
+`
+
+func TestDump(t *testing.T) {
+	f, fd := dumpFile(t)
+	dump := func(opts DumpOptions) string {
+		var out bytes.Buffer
+		opts.Name = "test"
+		require.NoError(t, Dump(&out, f, fd, opts))
+		return out.String()
+	}
+
+	assert.Equal(t, dumpHeader+`data:
+
+ x = 1, 2, 3 ;
+
+ names =
+  "ab",
+  "cd",
+  "efgh" ;
+
+ scalar = 1.5 ;
+
+ time = 0, 6.5 ;
+
+ temp =
+  0.1, 1e+10, _,
+  -2, 3, _ ;
+}
+`, dump(DumpOptions{}))
+
+	assert.Equal(t, dumpHeader+"}\n", dump(DumpOptions{HeaderOnly: true}))
+
+	assert.Equal(t, dumpHeader+`data:
+
+ x = 1, 2, 3 ;
+
+ time = "2000-01-01", "2000-01-01 06:30" ;
+}
+`, dump(DumpOptions{Coords: true, Times: true}))
+
+	assert.True(t, strings.HasSuffix(
+		dump(DumpOptions{Vars: []string{"scalar"}, Special: true}),
+		"\t\t:_Format = \"classic\" ;\ndata:\n\n scalar = 1.5 ;\n}\n",
+	))
+
+	var out bytes.Buffer
+	assert.EqualError(t, Dump(&out, f, fd, DumpOptions{Vars: []string{"other"}}), "Unknown variable `other`")
+}
+
+func TestDumpWrapsLines(t *testing.T) {
+	dims := []types.Dimension{{Name: "x", Len: 30}}
+	f := (&types.File{
+		Dimensions: dims,
+		Vars: types.Vars{
+			{Name: "v", Type: types.Int, Dimensions: []*types.Dimension{&dims[0]}},
+		}.Map(),
+	}).ComputeSizes()
+	var buf buffer
+	require.NoError(t, write.Header(f, &buf))
+	values := make([]int32, 30)
+	for i := range values {
+		values[i] = int32(i * 1000)
+	}
+	require.NoError(t, write.VarData(f, f.Vars.Get("v"), values, &buf))
+
+	var out bytes.Buffer
+	require.NoError(t, Dump(&out, f, bytes.NewReader(buf.Bytes()), DumpOptions{Name: "wrap"}))
+	assert.Contains(t, out.String(), `data:
+
+ v = 0, 1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000, 10000, 11000, 
+    12000, 13000, 14000, 15000, 16000, 17000, 18000, 19000, 20000, 21000, 
+    22000, 23000, 24000, 25000, 26000, 27000, 28000, 29000 ;
+}
+`)
+}
+
+func TestDumpStreamsChunks(t *testing.T) {
+	// each row is read as a chunk of its own
+	dims := []types.Dimension{{Name: "y", Len: 3}, {Name: "x", Len: chunkLen/2 + 1}}
+	f := (&types.File{
+		Dimensions: dims,
+		Vars: types.Vars{
+			{Name: "v", Type: types.Int, Dimensions: []*types.Dimension{&dims[0], &dims[1]}},
+		}.Map(),
+	}).ComputeSizes()
+	values := make([]int32, dims[0].Len*dims[1].Len)
+	for i := range values {
+		values[i] = int32(i)
+	}
+	var buf buffer
+	require.NoError(t, write.Header(f, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("v"), values, &buf))
+
+	var out bytes.Buffer
+	require.NoError(t, Dump(&out, f, bytes.NewReader(buf.Bytes()), DumpOptions{Name: "big"}))
+	assert.Equal(t, 2, strings.Count(out.String(), ",\n  "))
+
+	g, err := ParseFile("big.cdl", &out)
+	require.NoError(t, err)
+	assert.Equal(t, types.Ints(values...), g.Vars.Get("v").Data)
+}
+
+func TestDumpAttrValue(t *testing.T) {
+	assert.Equal(t, "1b, -1b", DumpAttrValue(types.Bytes(1, 255)))
+	assert.Equal(t, "1UB", DumpAttrValue(types.UBytes(1)))
+	assert.Equal(t, "1, 2", DumpAttrValue(types.Ints(1, 2)))
+	assert.Equal(t, "1e+20f", DumpAttrValue(types.Floats(1e20)))
+	assert.Equal(t, "NaN", DumpAttrValue(types.Doubles(math.NaN())))
+	assert.Equal(t, "-5L, 5UL", DumpAttrValue(types.Int64s(-5))+", "+DumpAttrValue(types.UInt64s(5)))
+}
+
+func TestDumpSelectsCoords(t *testing.T) {
+	dims := []types.Dimension{{Name: "x", Len: 2}, {Name: "y", Len: 3}}
+	coords := DumpOptions{Coords: true}
+	assert.True(t, coords.selects(types.Var{Name: "x", Dimensions: []*types.Dimension{&dims[0]}}))
+	assert.False(t, coords.selects(types.Var{Name: "x", Dimensions: []*types.Dimension{&dims[0], &dims[1]}}))
+	assert.False(t, coords.selects(types.Var{Name: "y", Dimensions: []*types.Dimension{&dims[0]}}))
+	assert.False(t, coords.selects(types.Var{Name: "x"}))
+}
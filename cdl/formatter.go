@@ -8,40 +8,52 @@ import (
 	"github.com/parro-it/ncdf/types"
 )
 
-// CDL ...
+// CDLFile returns the CDL header of f, without
+// the data section. See FormatFile.
 func CDLFile(f *types.File) string {
 	var res strings.Builder
-	res.WriteString("netcdf filename {\n")
-	res.WriteString(dimensionsCDL(f.Dimensions))
-	res.WriteString("variables\n")
-	for _, v := range f.Vars.Values() {
-		res.WriteString("    ")
-		res.WriteString(CDLVar(&v))
-	}
-	res.WriteRune('\n')
-	res.WriteString("// global attributes:\n")
-	res.WriteString(attributesCDL(f.Attrs, ""))
-	res.WriteRune('}')
-
+	FormatFile(&res, f, nil, FormatOptions{Name: "filename"})
 	return res.String()
 }
 
-// CDL ...
-func CDLDimension(f *types.Dimension) string {
-	return fmt.Sprintf("%s = %d;", f.Name, f.Len)
+// CDLDimension returns the CDL declaration of dimension f.
+// Unlimited dimensions are printed as ncdump does, with
+// numRecs, the current number of records, in a comment.
+func CDLDimension(f *types.Dimension, numRecs int64) string {
+	if f.IsUnlimited() {
+		return fmt.Sprintf("%s = UNLIMITED ; // (%d currently)", EscapeName(f.Name), numRecs)
+	}
+	return fmt.Sprintf("%s = %d;", EscapeName(f.Name), f.Len)
 }
 
 // CDL ...
 func CDLAttr(f *types.Attr) string {
-	value := f.Type.ValueToString(f.Val)
-	if f.Type == types.Char {
-		value = `"` + value + `"`
+	value := f.Val.String()
+	if f.Type() == types.Char {
+		value = quote(value)
 	}
 
-	return fmt.Sprintf("%s = "+value+";", f.Name)
+	return EscapeName(f.Name) + " = " + value + ";"
 
 }
 
+// EscapeName returns name as a CDL identifier: characters
+// not allowed in names, a leading digit and names equal
+// to keywords or to NaN and Infinity are escaped
+// with a backslash.
+func EscapeName(name string) string {
+	_, keyword := keywords[name]
+	_, special := specialFloats[name]
+	var res strings.Builder
+	for i, c := range name {
+		if i == 0 && (!isNameStart(c) || keyword || special) || i > 0 && !isNameChar(c) {
+			res.WriteByte('\\')
+		}
+		res.WriteRune(c)
+	}
+	return res.String()
+}
+
 // CDL ...
 func CDLVar(v *types.Var) string {
 	var dimS strings.Builder
@@ -50,13 +62,13 @@ func CDLVar(v *types.Var) string {
 		if i > 0 {
 			dimS.WriteString(", ")
 		}
-		dimS.WriteString(d.Name)
+		dimS.WriteString(EscapeName(d.Name))
 	}
 
 	var res strings.Builder
-	res.WriteString(fmt.Sprintf("%s %s(%s);\n", CDLType(v.Type), v.Name, dimS.String()))
+	res.WriteString(fmt.Sprintf("%s %s(%s);\n", CDLType(v.Type), EscapeName(v.Name), dimS.String()))
 
-	res.WriteString(attributesCDL(v.Attrs, v.Name))
+	res.WriteString(attributesCDL(v.Attrs, EscapeName(v.Name)))
 	return res.String()
 }
 
@@ -77,12 +89,12 @@ func CDLType(t types.Type) string {
 	return t.CDLName()
 }
 
-func dimensionsCDL(dd []types.Dimension) string {
+func dimensionsCDL(dd []types.Dimension, numRecs int64) string {
 	var res strings.Builder
 	res.WriteString("dimensions:\n")
 	for _, d := range dd {
 		res.WriteString("    ")
-		res.WriteString(CDLDimension(&d))
+		res.WriteString(CDLDimension(&d, numRecs))
 		res.WriteRune('\n')
 	}
 	return res.String()
@@ -1,10 +1,12 @@
 package cdl
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/parro-it/ncdf/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDimension(t *testing.T) {
@@ -12,7 +14,10 @@ func TestDimension(t *testing.T) {
 		Name: "test",
 		Len:  (42),
 	}
-	assert.Equal(t, "test = 42;", CDLDimension(&d))
+	assert.Equal(t, "test = 42;", CDLDimension(&d, 0))
+
+	d.Len = 0
+	assert.Equal(t, "test = UNLIMITED ; // (12 currently)", CDLDimension(&d, 12))
 }
 
 func TestDimensions(t *testing.T) {
@@ -23,17 +28,17 @@ func TestDimensions(t *testing.T) {
 	assert.Equal(t, `dimensions:
     test = 42;
     ciao-mondo = 12;
-`, dimensionsCDL(dd))
+`, dimensionsCDL(dd, 0))
 }
 
 func TestAttr(t *testing.T) {
 	aa := map[string]types.Attr{
-		"1-a = 42.42;":  {Name: "a", Type: types.Float, Val: 42.42},
-		"2-a = 42.42;":  {Name: "a", Type: types.Double, Val: 42.42},
-		"3-a = 42;":     {Name: "a", Type: types.Int, Val: 42},
-		"4-a = 42;":     {Name: "a", Type: types.Short, Val: 42},
-		`5-a = "ciao";`: {Name: "a", Type: types.Char, Val: "ciao"},
-		"6-a = 42;":     {Name: "a", Type: types.Byte, Val: 42},
+		"1-a = 42.42;":  {Name: "a", Val: types.Floats(42.42)},
+		"2-a = 42.42;":  {Name: "a", Val: types.Doubles(42.42)},
+		"3-a = 42;":     {Name: "a", Val: types.Ints(42)},
+		"4-a = 42;":     {Name: "a", Val: types.Shorts(42)},
+		`5-a = "ciao";`: {Name: "a", Val: types.Text("ciao")},
+		"6-a = 42;":     {Name: "a", Val: types.Bytes(42)},
 	}
 
 	for expected, actual := range aa {
@@ -45,12 +50,10 @@ func TestVar(t *testing.T) {
 		Dimensions: []*types.Dimension{{Name: "dim1"}, {Name: "dim2"}},
 		Attrs: types.Attrs{{
 			Name: "len",
-			Val:  42,
-			Type: types.Short,
+			Val:  types.Shorts(42),
 		}, {
 			Name: "alt",
-			Val:  142,
-			Type: types.Int,
+			Val:  types.Ints(142),
 		}}.Map(),
 		Name: "test",
 		Type: types.Double,
@@ -60,3 +63,44 @@ func TestVar(t *testing.T) {
         test:alt = 142;
 `, CDLVar(&v))
 }
+
+func TestEscapeName(t *testing.T) {
+	names := map[string]string{
+		"air_temperature": "air_temperature",
+		"sea-level":       "sea-level",
+		"2m_temp":         `\2m_temp`,
+		"a b":             `a\ b`,
+		"a:b":             `a\:b`,
+		"data":            `\data`,
+		"NaN":             `\NaN`,
+		"température":     "température",
+	}
+	for name, expected := range names {
+		assert.Equal(t, expected, EscapeName(name))
+	}
+
+	a := types.Attr{Name: "a b", Val: types.Text("say \"hi\"\n")}
+	assert.Equal(t, `a\ b = "say \"hi\"\n";`, CDLAttr(&a))
+}
+
+func TestEscapeRoundTrip(t *testing.T) {
+	f := types.File{
+		Dimensions: []types.Dimension{{Name: "2d", Len: 1}},
+	}
+	v := types.Var{
+		Name:       "sea-level",
+		Type:       types.Float,
+		Dimensions: []*types.Dimension{&f.Dimensions[0]},
+		Attrs:      types.Attrs{{Name: "long name", Val: types.Text("a \"b\"\tc")}}.Map(),
+	}
+	v.Size = v.ValueByteSize()
+	f.Vars = types.Vars{v}.Map()
+
+	code := "netcdf x {\n" + dimensionsCDL(f.Dimensions, 0) + "variables:\n" + CDLVar(&v) + "}"
+	tks, errs := Tokenize(strings.NewReader(code))
+	p := Parser{Tokens: tks, Errors: errs}
+	parsed, err := p.Parse()
+	require.NoError(t, err)
+	assert.Equal(t, f.Dimensions, parsed.Dimensions)
+	assert.Equal(t, v, parsed.Vars.Get("sea-level"))
+}
@@ -0,0 +1,203 @@
+package cdl
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/parro-it/ncdf/types"
+)
+
+// suffixTypes maps the type suffixes of numeric
+// literals, lower cased, to the type they select.
+var suffixTypes = map[string]types.Type{
+	"b":   types.Byte,
+	"ub":  types.UByte,
+	"s":   types.Short,
+	"us":  types.UShort,
+	"u":   types.UInt,
+	"l":   types.Int64,
+	"ll":  types.Int64,
+	"ul":  types.UInt64,
+	"ull": types.UInt64,
+	"f":   types.Float,
+	"d":   types.Double,
+}
+
+// specialFloats contains the literals of non finite
+// floating point values, with their type.
+var specialFloats = map[string]types.Type{
+	"NaN":       types.Double,
+	"NaNf":      types.Float,
+	"Infinity":  types.Double,
+	"Infinityf": types.Float,
+}
+
+var (
+	decimalRe = regexp.MustCompile(`^([+-]?(?:\d+\.?\d*|\.\d+)([eE][+-]?\d+)?)([a-zA-Z]*)$`)
+	hexRe     = regexp.MustCompile(`^([+-]?0[xX][0-9a-fA-F]+)([sSlLuU]*)$`)
+)
+
+// numberToken returns the token of the numeric literal text:
+// a decimal or hexadecimal number, optionally signed and
+// followed by a type suffix, or one of NaN, NaNf, Infinity
+// and Infinityf. Token.NumType is set only when the
+// literal has a type suffix.
+func numberToken(text string) (Token, error) {
+	tk := Token{Type: TkInt, Text: text}
+
+	unsigned := strings.TrimLeft(text, "+-")
+	if t, ok := specialFloats[unsigned]; ok {
+		tk.Type = TkDec
+		if strings.HasSuffix(unsigned, "f") {
+			tk.NumType = t
+		}
+		tk.NumVal = math.NaN()
+		if strings.HasPrefix(unsigned, "Infinity") {
+			tk.NumVal = math.Inf(1)
+			if strings.HasPrefix(text, "-") {
+				tk.NumVal = math.Inf(-1)
+			}
+		}
+		return tk, nil
+	}
+
+	var num, suffix string
+	if m := matchHex(text); m != nil {
+		num, suffix = m[1], m[2]
+		v, err := strconv.ParseInt(num, 0, 64)
+		if err != nil {
+			u, uerr := strconv.ParseUint(strings.TrimLeft(num, "+"), 0, 64)
+			if uerr != nil {
+				return Token{}, fmt.Errorf("invalid number `%s`", text)
+			}
+			tk.NumVal = float64(u)
+		} else {
+			tk.NumVal = float64(v)
+		}
+	} else if m := matchDecimal(text); m != nil {
+		num, suffix = m[1], m[3]
+		if strings.ContainsAny(num, ".eE") {
+			tk.Type = TkDec
+		}
+		v, err := strconv.ParseFloat(num, 64)
+		if err != nil && !math.IsInf(v, 0) {
+			return Token{}, fmt.Errorf("invalid number `%s`", text)
+		}
+		tk.NumVal = v
+	} else {
+		return Token{}, fmt.Errorf("invalid number `%s`", text)
+	}
+
+	if suffix != "" {
+		t, ok := suffixTypes[strings.ToLower(suffix)]
+		if !ok {
+			return Token{}, fmt.Errorf("invalid suffix `%s` of number `%s`", suffix, text)
+		}
+		if tk.Type == TkDec && t != types.Float && t != types.Double {
+			return Token{}, fmt.Errorf("invalid suffix `%s` of decimal number `%s`", suffix, text)
+		}
+		if t == types.Float || t == types.Double {
+			tk.Type = TkDec
+		}
+		tk.NumType = t
+	}
+	return tk, nil
+}
+
+// matchHex returns the submatches of hexRe in text, or nil.
+func matchHex(text string) []string {
+	if !strings.ContainsAny(text, "xX") {
+		return nil
+	}
+	return hexRe.FindStringSubmatch(text)
+}
+
+// matchDecimal returns the submatches of decimalRe in text,
+// or nil. Numbers made only of digits, signs, dots and
+// exponents, that are most of them, are matched without
+// the cost of the regexp: they are validated when parsed.
+func matchDecimal(text string) []string {
+	if text == "" {
+		return nil
+	}
+	for i := 0; i < len(text); i++ {
+		if c := text[i]; (c < '0' || c > '9') && c != '+' && c != '-' && c != '.' && c != 'e' && c != 'E' {
+			return decimalRe.FindStringSubmatch(text)
+		}
+	}
+	if c := text[len(text)-1]; (c < '0' || c > '9') && c != '.' {
+		return decimalRe.FindStringSubmatch(text)
+	}
+	return []string{text, text, "", ""}
+}
+
+// Value returns the value of a numeric token, typed
+// following its suffix or, without suffix, as ncgen does:
+// decimal numbers are NC_DOUBLE, and integers are NC_INT,
+// or NC_INT64 or NC_UINT64 when out of the NC_INT range.
+// Integers are parsed from the literal, to not lose
+// precision.
+func (t Token) Value() (types.Value, error) {
+	if t.Type != TkInt && t.Type != TkDec {
+		return types.Value{}, fmt.Errorf("`%s` is not a number", t.Text)
+	}
+
+	typ := t.NumType
+	if typ == types.Unknown && t.Type == TkDec {
+		typ = types.Double
+	}
+
+	if t.Type == TkDec || typ == types.Float || typ == types.Double {
+		val, err := types.Doubles(t.NumVal).Convert(typ)
+		if err != nil {
+			return types.Value{}, fmt.Errorf("number `%s` out of range of type %s", t.Text, typ)
+		}
+		return val, nil
+	}
+
+	// integers are parsed again without the suffix
+	num := strings.TrimRight(t.Text, "sSlLuUbB")
+	if strings.HasPrefix(strings.TrimLeft(num, "+-"), "0x") || strings.HasPrefix(strings.TrimLeft(num, "+-"), "0X") {
+		num = strings.TrimRight(t.Text, "sSlLuU")
+	}
+	base := 10
+	if i := strings.IndexAny(num, "xX"); i >= 0 {
+		base = 16
+		num = num[:i-1] + num[i+1:]
+	}
+
+	if typ == types.Unknown {
+		// the type follows the parsed integer: as a
+		// float64, NumVal is the same for 2^63 and
+		// math.MaxInt64
+		typ = types.Int
+		if i, err := strconv.ParseInt(num, base, 64); err != nil {
+			typ = types.UInt64
+		} else if i < math.MinInt32 || i > math.MaxInt32 {
+			typ = types.Int64
+		}
+	}
+
+	var val types.Value
+	if typ == types.UInt64 || typ == types.UInt || typ == types.UShort || typ == types.UByte {
+		u, err := strconv.ParseUint(strings.TrimPrefix(num, "+"), base, 64)
+		if err != nil {
+			return types.Value{}, fmt.Errorf("number `%s` out of range of type %s", t.Text, typ)
+		}
+		val = types.UInt64s(u)
+	} else {
+		i, err := strconv.ParseInt(num, base, 64)
+		if err != nil {
+			return types.Value{}, fmt.Errorf("number `%s` out of range of type %s", t.Text, typ)
+		}
+		val = types.Int64s(i)
+	}
+	res, err := val.Convert(typ)
+	if err != nil {
+		return types.Value{}, fmt.Errorf("number `%s` out of range of type %s", t.Text, typ)
+	}
+	return res, nil
+}
@@ -1,264 +1,470 @@
 package cdl
 
 import (
+	"bytes"
 	"fmt"
-	"log"
+	"io"
+	"math"
+	"strings"
 
 	"github.com/parro-it/ncdf/types"
 )
 
-// Parser ...
+// Parser parses CDL sources in a types.File. The grammar
+// is the one of ParseDocument: each statement is converted
+// as soon as it's parsed, and semantic errors, like unknown
+// names or values out of range, are reported at the
+// position of their node.
 type Parser struct {
+	// Lexer is the source of tokens. When nil,
+	// they are received from Tokens.
+	Lexer  *Lexer
 	Tokens chan Token
-	last   Token
+	// Errors receives the error that stopped the
+	// tokenizer sending Tokens, as returned by Tokenize.
+	Errors <-chan error
+	// File is the name of the parsed file,
+	// used in diagnostics.
+	File string
+	// Source is the parsed text, used to
+	// add snippets to diagnostics.
+	Source string
+
+	f          *types.File
+	dimensions map[string]*types.Dimension
 }
 
-func (p *Parser) consume() bool {
-	p.last = <-p.Tokens
-	return p.last.Type == TkEmpty
+// ParseFile parses the CDL source read from r. name is
+// the name of the file reported in diagnostics.
+// Errors found in the source are returned as Diagnostics.
+func ParseFile(name string, r io.Reader) (*types.File, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := Parser{
+		Lexer:  NewLexer(bytes.NewReader(src)),
+		File:   name,
+		Source: string(src),
+	}
+	return p.Parse()
 }
 
-func (p *Parser) parseDimensions(f *types.File) bool {
-	if p.consume() || p.last.Type != TkColon {
-		log.Panicf("`:` is required after a `dimensions` directive")
+// Parse parses the tokens of a CDL file. Parsing continues
+// after errors, that are all returned as Diagnostics.
+func (p *Parser) Parse() (*types.File, error) {
+	p.f = new(types.File)
+	p.dimensions = nil
+	dp := docParser{src: p.next, file: p.File, source: p.Source, lower: p.lower}
+	dp.parse()
+	if err := dp.err(); err != nil {
+		return nil, err
 	}
-	f.Dimensions = []types.Dimension{}
-	for {
-		if p.consume() || p.last.Type == TkCurClose {
-			return false
-		}
-		if p.last.Type == TkData || p.last.Type == TkVariables {
-			return true
-		}
-		var d types.Dimension
-		if p.last.Type != TkName {
-			panic("dimension name expected")
-		}
-		d.Name = p.last.Text
-		if p.consume() || p.last.Type != TkEqual {
-			panic("`=` expected")
-		}
+	return p.f, nil
+}
 
-		if p.consume() || p.last.Type != TkInt {
-			panic("dimension name expected")
-		}
-		d.Len = int32(p.last.NumVal)
-		f.Dimensions = append(f.Dimensions, d)
-		if p.consume() || p.last.Type != TkSemicolon {
-			panic("`;` expected")
+// next returns the next token of the Lexer or of Tokens.
+func (p *Parser) next() (Token, error) {
+	if p.Lexer != nil {
+		return p.Lexer.Next()
+	}
+	tk, ok := <-p.Tokens
+	if !ok && p.Errors != nil {
+		if err := <-p.Errors; err != nil {
+			return Token{}, err
 		}
 	}
+	return tk, nil
 }
 
-// TODO: parse attributes
-func (p *Parser) parseVariables(f *types.File) bool {
-	if p.consume() || p.last.Type != TkColon {
-		log.Panicf("`:` is required after a `variables` directive")
-	}
-
-	if p.consume() || p.last.Type == TkCurClose {
-		return false
-	}
+// errorf fails the conversion of the current
+// node, with a formatted message.
+func (p *Parser) errorf(format string, args ...interface{}) {
+	panic(&Diagnostic{Msg: fmt.Sprintf(format, args...)})
+}
 
-	dimensions := mapDimensions(f)
+// errorAt fails the conversion of the current node,
+// with a formatted message at the position of the
+// token tk.
+func (p *Parser) errorAt(tk Token, format string, args ...interface{}) {
+	panic(&Diagnostic{Pos: tk.Pos.Start, Found: tk.Text, Msg: fmt.Sprintf(format, args...)})
+}
 
-	for {
-		if p.last.Type == TkVarType {
-			v := p.parseVariable(dimensions)
-			f.Vars.Set(v.Name, v)
-		} else if p.last.Type == TkName || p.last.Type == TkColon {
-			a, v := p.parseAttribute(f, dimensions)
-			if v == nil {
-				f.Attrs.Set(a.Name, a)
-			} else {
-				v.Attrs.Set(a.Name, a)
-				f.Vars.Set(v.Name, *v)
+// lower adds node n, a section that starts or one of its
+// statements, to the parsed file. Errors without a position
+// are found at the start of n.
+func (p *Parser) lower(n Node) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			d, ok := e.(*Diagnostic)
+			if !ok {
+				d = &Diagnostic{Msg: fmt.Sprint(e)}
 			}
-
-		} else {
-			log.Panicf("unexpected token %v", p.last)
+			if d.Pos.Row == 0 {
+				d.Pos = n.Info().Pos.Start
+			}
+			err = d
 		}
+	}()
 
-		if p.last.Type == TkCurClose {
-			return false
+	switch n := n.(type) {
+	case *Section:
+		switch n.Kind {
+		case TkDimensions:
+			p.f.Dimensions = []types.Dimension{}
+		case TkVariables:
+			p.dimensions = mapDimensions(p.f)
 		}
-
-		if p.last.Type == TkDimensions || p.last.Type == TkData || p.last.Type == TkVariables {
-			return true
+	case *DimDecl:
+		for _, spec := range n.Specs {
+			p.lowerDimension(spec)
 		}
-
+	case *VarDecl:
+		for _, spec := range n.Specs {
+			v := p.lowerVariable(n.Type, spec)
+			p.f.Vars.Set(v.Name, v)
+		}
+	case *AttrDecl:
+		p.lowerAttribute(n)
+	case *DataStmt:
+		p.lowerVarData(n)
 	}
+	return nil
 }
 
-func (p *Parser) parseAttribute(f *types.File, dimensions map[string]*types.Dimension) (types.Attr, *types.Var) {
-	var a types.Attr
-	var v *types.Var
-	if p.last.Type == TkName {
-		tmp := f.Vars.Get(p.last.Text)
-		v = &tmp
-
-		if p.consume() || p.last.Type != TkColon {
-			panic(": expected")
+// lowerDimension adds the dimension declared by spec.
+func (p *Parser) lowerDimension(spec *DimSpec) {
+	d := types.Dimension{Name: spec.Name}
+	if spec.Len != "UNLIMITED" {
+		tk, err := numberToken(spec.Len)
+		if err != nil {
+			panic(err)
 		}
+		d.Len = int64(tk.NumVal)
 	}
-
-	if p.consume() || p.last.Type != TkName {
-		panic("attribute name expected")
+	if d.IsUnlimited() {
+		for _, other := range p.f.Dimensions {
+			if other.IsUnlimited() {
+				p.errorAt(Token{Pos: spec.Pos, Text: spec.Name}, "dimension `%s` is unlimited, `%s` cannot be unlimited too", other.Name, d.Name)
+			}
+		}
 	}
-	a.Name = p.last.Text
+	p.f.Dimensions = append(p.f.Dimensions, d)
+}
 
-	if p.consume() || p.last.Type != TkEqual {
-		panic("attribute name expected")
+// lowerVariable returns the variable of
+// type typeName declared by spec.
+func (p *Parser) lowerVariable(typeName string, spec *VarSpec) types.Var {
+	var v types.Var
+	v.Type = types.FromCDLName(typeName)
+	if v.Type == types.Unknown {
+		p.errorf("type `%s` is not supported for variables", typeName)
 	}
+	v.Name = spec.Name
 
-	if p.consume() {
-		panic("attribute value expected")
+	for _, id := range spec.Dims {
+		d, ok := p.dimensions[id.Name]
+		if !ok {
+			p.errorAt(Token{Pos: id.Pos, Text: id.Name}, "unknown dimension name `%s`", id.Name)
+		}
+		if d.IsUnlimited() && len(v.Dimensions) > 0 {
+			p.errorAt(Token{Pos: id.Pos, Text: id.Name}, "unlimited dimension `%s` must be the first dimension of variable `%s`", d.Name, v.Name)
+		}
+		v.Dimensions = append(v.Dimensions, d)
 	}
+	v.Size = v.ValueByteSize()
+	return v
+}
 
-	if p.last.Type == TkDec {
-		a.Val = []float32{float32(p.last.NumVal)}
-		a.Type = types.Float
-	} else if p.last.Type == TkInt {
-		a.Val = []int16{int16(p.last.NumVal)}
-		a.Type = types.Short
-	} else if p.last.Type == TkStr {
-		a.Val = []byte(p.last.Text)
-		a.Type = types.Char
-	} else {
-		panic("unsupported type")
+// lowerAttribute adds the attribute defined by n to the
+// global attributes or to the ones of its variable.
+func (p *Parser) lowerAttribute(n *AttrDecl) {
+	if n.Var != "" && !p.f.Vars.Has(n.Var) {
+		p.errorf("unknown variable `%s`", n.Var)
 	}
-	if p.consume() || p.last.Type != TkSemicolon {
-		panic("; expected")
+
+	typeName := n.Type
+	if n.Var != "" && n.Name == "_FillValue" {
+		// the fill value has the type of
+		// its variable, as in ncgen
+		vt := p.f.Vars.Get(n.Var).Type
+		if typeName == "" {
+			typeName = vt.CDLName()
+		} else if attrType(typeName) != vt {
+			p.errorf("type %s of `%s:_FillValue` differs from the type %s of the variable", attrType(typeName), n.Var, vt)
+		}
 	}
-	if p.consume() {
-		panic("} expected")
+	a := types.Attr{Name: n.Name, Val: p.attrValue(n, typeName)}
+
+	if n.Var == "" && a.Name == "_Format" {
+		// the special attribute selects the
+		// format of the file, as in ncgen
+		ver, err := ParseFormatName(a.Val.String())
+		if a.Type() != types.Char || err != nil {
+			p.errorf("unknown format `%s`", a.Val)
+		}
+		p.f.Version = ver
+	} else if n.Var == "" {
+		p.f.Attrs.Set(a.Name, a)
+	} else {
+		v := p.f.Vars.Get(n.Var)
+		v.Attrs.Set(a.Name, a)
+		p.f.Vars.Set(v.Name, v)
 	}
-	return a, v
 }
 
-func (p *Parser) parseVariable(dimensions map[string]*types.Dimension) types.Var {
-	var v types.Var
-	if p.last.Type != TkVarType {
-		log.Panicf("variable type expected")
+// attrType returns the type selected
+// by the type prefix of attributes.
+func attrType(typeName string) types.Type {
+	if typeName == "string" {
+		return types.Char
 	}
-	size := 1
-	for _, d := range dimensions {
-		size *= int(d.Len)
+	return types.FromCDLName(typeName)
+}
+
+// attrValue returns the value of the attribute defined by
+// n, of type typeName. Strings are concatenated. When
+// typeName is empty, numbers are converted to the narrowest
+// type that can represent all of them, as ncgen does.
+func (p *Parser) attrValue(n *AttrDecl, typeName string) types.Value {
+	values := make([]types.Value, len(n.Values))
+	strs := 0
+	for i, lit := range n.Values {
+		if lit.Kind == TkStr {
+			values[i] = types.Text(lit.Text)
+			strs++
+			continue
+		}
+		val, err := lit.token().Value()
+		if err != nil {
+			p.errorAt(lit.token(), "%v", err)
+		}
+		values[i] = val
 	}
-	v.Type = types.FromCDLName(p.last.Text)
-	v.Size = int32(v.Type.ArraySize(size))
 
-	if p.consume() || p.last.Type != TkName {
-		panic("variable name expected")
+	var t types.Type
+	if typeName == "" {
+		if strs > 0 && strs < len(values) {
+			p.errorf("attribute `%s` mixes string and numeric values", n.Name)
+		}
+		t = values[0].Type()
+		for _, val := range values[1:] {
+			t = promote(t, val.Type())
+		}
+	} else {
+		t = attrType(typeName)
 	}
-	v.Name = p.last.Text
 
-	if p.consume() || p.last.Type != TkParOpen {
-		panic("dimension list expected")
+	if t == types.Char && strs < len(values) {
+		p.errorf("numeric value for attribute `%s` of type %s", n.Name, t)
+	}
+	if t != types.Char && strs > 0 {
+		p.errorf("string value for attribute `%s` of type %s", n.Name, t)
 	}
 
-	for {
-		if p.consume() || p.last.Type != TkName {
-			panic("dimension name expected")
+	converted := make([]types.Value, len(values))
+	for i, val := range values {
+		c, err := val.Convert(t)
+		if err != nil {
+			p.errorAt(n.Values[i].token(), "invalid value for attribute `%s`: %v", n.Name, err)
 		}
-		d, ok := dimensions[p.last.Text]
-		if !ok {
-			log.Panicf("unknown dimension name `%s`", p.last.Text)
-		}
-		if p.consume() {
-			panic("dimension list not closed by `)`")
-		}
-
-		v.Dimensions = append(v.Dimensions, d)
+		converted[i] = c
+	}
+	res, err := types.Append(t, converted...)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
 
-		if p.last.Type == TkParClose {
-			if p.consume() || p.last.Type != TkSemicolon {
-				panic("`;` expected")
-			}
-			if p.consume() {
-				panic("`}` expected")
-			}
+// promotionOrder lists numeric types from
+// the narrowest to the widest.
+var promotionOrder = []types.Type{
+	types.Byte, types.UByte, types.Short, types.UShort, types.Int,
+	types.UInt, types.Int64, types.UInt64, types.Float, types.Double,
+}
 
-			return v
+// promote returns the narrowest type that can
+// represent values of both types a and b.
+func promote(a, b types.Type) types.Type {
+	if a == b {
+		return a
+	}
+	for _, t := range promotionOrder {
+		if covers(t, a) && covers(t, b) {
+			return t
 		}
+	}
+	return types.Double
+}
+
+// covers returns whether every value of type
+// x can be represented in type t.
+func covers(t, x types.Type) bool {
+	if t == x || t == types.Double {
+		return true
+	}
+	if t == types.Float {
+		return x == types.Byte || x == types.UByte || x == types.Short || x == types.UShort
+	}
+	tmin, tmax, ok := intRange(t)
+	xmin, xmax, xok := intRange(x)
+	return ok && xok && tmin <= xmin && tmax >= xmax
+}
 
+// intRange returns the range of values of integer type t.
+func intRange(t types.Type) (min, max float64, ok bool) {
+	switch t {
+	case types.Byte:
+		return math.MinInt8, math.MaxInt8, true
+	case types.UByte:
+		return 0, math.MaxUint8, true
+	case types.Short:
+		return math.MinInt16, math.MaxInt16, true
+	case types.UShort:
+		return 0, math.MaxUint16, true
+	case types.Int:
+		return math.MinInt32, math.MaxInt32, true
+	case types.UInt:
+		return 0, math.MaxUint32, true
+	case types.Int64:
+		return math.MinInt64, math.MaxInt64, true
+	case types.UInt64:
+		return 0, math.MaxUint64, true
 	}
+	return 0, 0, false
 }
 
 func mapDimensions(f *types.File) map[string]*types.Dimension {
 	dimensions := map[string]*types.Dimension{}
-	for _, d := range f.Dimensions {
-		dimensions[d.Name] = &d
+	for i := range f.Dimensions {
+		dimensions[f.Dimensions[i].Name] = &f.Dimensions[i]
 	}
 	return dimensions
 }
 
-// TODO: parse CDL data
-func (p *Parser) parseData(f *types.File) bool {
-	return false
-}
-
-func (p *Parser) parseStatement(f *types.File) bool {
-
-	switch p.last.Type {
-	case TkDimensions:
-		return p.parseDimensions(f)
-	case TkVariables:
-		return p.parseVariables(f)
-	case TkData:
-		return p.parseData(f)
-	case TkCurClose:
-		return false
-	default:
-		log.Panicf("unexpected token %v", p.last)
+// lowerVarData sets the values of the
+// variable assigned by n in the data section.
+func (p *Parser) lowerVarData(n *DataStmt) {
+	if !p.f.Vars.Has(n.Var) {
+		p.errorf("unknown variable `%s`", n.Var)
+	}
+	v := p.f.Vars.Get(n.Var)
+	v.Data = p.lowerValues(v, n.Values)
+	p.f.Vars.Set(v.Name, v)
+
+	if v.IsRecord() {
+		recLen := int(v.RecordLen())
+		numRecs := int64((v.Data.Len() + recLen - 1) / recLen)
+		if numRecs > p.f.NumRecs {
+			p.f.NumRecs = numRecs
+		}
 	}
-
-	return false
 }
 
-// Parse ...
+// lowerValues returns the values of variable v in the data
+// section. `_` stands for the fill value of the variable.
+// Strings assigned to char variables are padded with NUL
+// characters to a multiple of the length of the last
+// dimension. Variables that are not record variables
+// cannot get more values than they contain.
+func (p *Parser) lowerValues(v types.Var, lits []*Literal) types.Value {
+	fill, err := v.FillValue()
+	if err != nil {
+		panic(err)
+	}
 
-func (p *Parser) Parse() (f *types.File, err error) {
-	f = new(types.File)
-	defer func() {
-		if e := recover(); e != nil {
-			f = nil
-			err = fmt.Errorf("Parse failed: %v", e)
+	var values []types.Value
+	// numbers contains the values of lits[from:] not yet
+	// appended to values: they are converted to the type
+	// of v all at once, unless they are 64 bits integers
+	// that could lose precision as float64.
+	var numbers []float64
+	from := 0
+	flush := func() {
+		if len(numbers) == 0 {
+			return
 		}
+		val, err := types.Doubles(numbers...).Convert(v.Type)
+		if err != nil {
+			for i, x := range numbers {
+				if _, err := types.Doubles(x).Convert(v.Type); err != nil {
+					p.errorAt(lits[from+i].token(), "invalid data for variable `%s`: %v", v.Name, err)
+				}
+			}
+		}
+		values = append(values, val)
+		numbers = nil
+	}
+	exact := v.Type == types.Int64 || v.Type == types.UInt64
 
-	}()
-	p.parseProgram(f)
-	return
-}
-
-func (p *Parser) parseProgram(f *types.File) {
-	p.consume()
+	// count is the number of values of lits[:i]
+	count, size := int64(0), v.RecordLen()
+	for i, lit := range lits {
+		if len(numbers) == 0 {
+			from = i
+		}
+		n := int64(1)
+		if lit.Kind == TkStr && v.Type == types.Char {
+			n = int64(len(padString(v, lit.Text)))
+		}
+		if count += n; !v.IsRecord() && count > size {
+			p.errorAt(lit.token(), "too many values for variable `%s`, that has %d", v.Name, size)
+		}
 
-	if p.last.Type != TkNetCdf {
-		panic("expected netcdf word")
+		switch lit.Kind {
+		case TkName:
+			flush()
+			values = append(values, fill)
+		case TkStr:
+			if v.Type != types.Char {
+				p.errorAt(lit.token(), "string value for variable `%s` of type %s", v.Name, v.Type)
+			}
+			flush()
+			values = append(values, types.Text(padString(v, lit.Text)))
+		default:
+			if v.Type == types.Char {
+				p.errorAt(lit.token(), "numeric value for variable `%s` of type %s", v.Name, v.Type)
+			}
+			if !exact {
+				numbers = append(numbers, lit.num)
+				break
+			}
+			val, err := lit.token().Value()
+			if err == nil {
+				val, err = val.Convert(v.Type)
+			}
+			if err != nil {
+				p.errorAt(lit.token(), "invalid data for variable `%s`: %v", v.Name, err)
+			}
+			values = append(values, val)
+		}
 	}
+	flush()
 
-	p.consume()
-	if p.last.Type != TkName {
-		panic("expected file name")
+	res, err := types.Append(v.Type, values...)
+	if err != nil {
+		panic(err)
 	}
+	return res
+}
 
-	p.consume()
-	if p.last.Type != TkCurOpen {
-		panic("expected {")
+// padString pads s with NUL characters to a multiple
+// of the length of the last dimension of char variable v.
+func padString(v types.Var, s string) string {
+	if len(v.Dimensions) == 0 {
+		return s
 	}
-	if p.consume() {
-		panic("expected }")
+	last := v.Dimensions[len(v.Dimensions)-1]
+	if last.IsUnlimited() {
+		return s
 	}
-	for p.parseStatement(f) {
-
+	n := int(last.Len)
+	if n == 0 {
+		return s
 	}
-
-	if p.last.Type != TkCurClose {
-		panic("expected }")
-	}
-
-	if !p.consume() {
-		log.Panicf("unexpected %v", p.last)
+	if rest := len(s) % n; rest != 0 || len(s) == 0 {
+		s += strings.Repeat("\x00", n-rest)
 	}
+	return s
 }
@@ -1,24 +1,24 @@
 package cdl
 
 import (
+	"math"
 	"strings"
 	"testing"
 
 	"github.com/parro-it/ncdf/ordmap"
 	"github.com/parro-it/ncdf/types"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func assertParseTo(t *testing.T, code string, expectedFile *types.File, expectedErr string) {
 	r := strings.NewReader(code)
 
-	tks, _ := Tokenize(r)
+	tks, errs := Tokenize(r)
 
-	p := Parser{Tokens: tks}
+	p := Parser{Tokens: tks, Errors: errs}
 	f, err := p.Parse()
 	if expectedErr == "" {
-		// TODO: check for tokenizer errors
-		//require.NoError(t, <-tknErr)
 		require.NoError(t, err)
 	} else {
 		require.EqualError(t, err, expectedErr)
@@ -43,14 +43,12 @@ func TestAttributes(t *testing.T) {
 			Size:       4,
 			Attrs: types.Attrs{{
 				Name: "len",
-				Type: types.Short,
-				Val:  []int16{15},
+				Val:  types.Ints(15),
 			}}.Map(),
 		}}.Map(),
 		Attrs: types.Attrs{{
 			Name: "lon",
-			Type: types.Short,
-			Val:  []int16{45},
+			Val:  types.Ints(45),
 		}}.Map(),
 	}, "")
 
@@ -69,11 +67,12 @@ func TestFailures(t *testing.T) {
 		}}.Map(),
 	}, "")
 
-	assertParseTo(t, "netcdf fname {variables:float pippo (a)}", nil, "Parse failed: unknown dimension name `a`")
+	assertParseTo(t, "netcdf fname {variables:float pippo (a);}", nil, "Parse failed: unknown dimension name `a`")
+	assertParseTo(t, "netcdf fname {dimensions: a=10; variables:float pippo (a)}", nil, "Parse failed: `,` or `;` expected")
 	assertParseTo(t, "netcdf fname {variables:float pippo (}", nil, "Parse failed: dimension name expected")
 	assertParseTo(t, "netcdf fname {variables:float pippo }", nil, "Parse failed: dimension list expected")
 	assertParseTo(t, "netcdf fname {variables:float }", nil, "Parse failed: variable name expected")
-	assertParseTo(t, "netcdf fname {variables:wrong}", nil, "Parse failed: : expected")
+	assertParseTo(t, "netcdf fname {variables:wrong}", nil, "Parse failed: `:` expected")
 	assertParseTo(t, "netcdf fname {variables:}", &types.File{Vars: ordmap.OrderedMap[types.Var, string]{}}, "")
 
 	assertParseTo(t, "netcdf fname {dimensions: a=10; b = 20;}", &types.File{Dimensions: []types.Dimension{
@@ -82,12 +81,241 @@ func TestFailures(t *testing.T) {
 	}}, "")
 
 	assertParseTo(t, "netcdf fname {}", &types.File{}, "")
-	assertParseTo(t, "ciao", nil, "Parse failed: expected netcdf word")
-	assertParseTo(t, "netcdf {", nil, "Parse failed: expected file name")
-	assertParseTo(t, "netcdf fname", nil, "Parse failed: expected {")
-	assertParseTo(t, "netcdf fname {", nil, "Parse failed: expected }")
+	assertParseTo(t, "ciao", nil, "Parse failed: `netcdf` expected")
+	assertParseTo(t, "netcdf {", nil, "Parse failed: dataset name expected")
+	assertParseTo(t, "netcdf fname", nil, "Parse failed: `{` expected")
+	assertParseTo(t, "netcdf fname {", nil, "Parse failed: `}` expected")
 	assertParseTo(t, "netcdf fname {dimensions}", nil, "Parse failed: `:` is required after a `dimensions` directive")
 	assertParseTo(t, "netcdf fname {dimensions:}", &types.File{Dimensions: []types.Dimension{}}, "")
 	assertParseTo(t, "netcdf fname {variables}", nil, "Parse failed: `:` is required after a `variables` directive")
+	assertParseTo(t, "netcdf fname {dimensions: a = 1.2.3;}", nil, "Parse failed: unexpected dot")
 
 }
+
+func TestData(t *testing.T) {
+	code := `netcdf fname {
+	dimensions: time = 0; x = 2; len = 3;
+	variables:
+		short temp(time, x);
+		temp:_FillValue = 99;
+		char names(x, len);
+		double time(time);
+	data:
+		temp = 1, 2, 3, _, 5;
+		names = "ab", "cde";
+		time = 0.5;
+	}`
+	tks, errs := Tokenize(strings.NewReader(code))
+	p := Parser{Tokens: tks, Errors: errs}
+	f, err := p.Parse()
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), f.NumRecs)
+	assert.Equal(t, types.Shorts(1, 2, 3, 99, 5), f.Vars.Get("temp").Data)
+	assert.Equal(t, "ab\x00cde", f.Vars.Get("names").Data.String())
+	assert.Equal(t, types.Doubles(0.5), f.Vars.Get("time").Data)
+	assert.Equal(t, &f.Dimensions[1], f.Vars.Get("temp").Dimensions[1])
+	assert.Equal(t, int64(4), f.Vars.Get("temp").Size)
+
+	assertParseTo(t, "netcdf fname {dimensions: a=1; variables: float v(a); data: w = 1;}", nil, "Parse failed: unknown variable `w`")
+	assertParseTo(t, "netcdf fname {dimensions: a=1; variables: float v(a); data: v = 1 2;}", nil, "Parse failed: `,` or `;` expected")
+	assertParseTo(t, `netcdf fname {dimensions: a=1; variables: float v(a); data: v = "a";}`, nil, "Parse failed: string value for variable `v` of type NC_FLOAT")
+	assertParseTo(t, "netcdf fname {dimensions: a=1; variables: byte v(a); data: v = 300;}", nil, "Parse failed: invalid data for variable `v`: Value 300 out of range of type NC_BYTE")
+	assertParseTo(t, "netcdf fname {dimensions: a=2; variables: short v(a); data: v = 1, _, 3;}", nil, "Parse failed: too many values for variable `v`, that has 2")
+	assertParseTo(t, "netcdf fname {variables: double v; data: v = 1, 2;}", nil, "Parse failed: too many values for variable `v`, that has 1")
+	assertParseTo(t, `netcdf fname {dimensions: a=2; b=3; variables: char v(a, b); data: v = "ab", "cde", "f";}`, nil, "Parse failed: too many values for variable `v`, that has 6")
+}
+
+func TestTypedAttributes(t *testing.T) {
+	code := `netcdf fname {variables:
+		:a = -1.5e10d; :b = 2.5; :c = 1.5f; :d = -3s; :e = 200UB; :f = 0x10;
+		:g = 9223372036854775807; :h = 5000000000; :i = 18446744073709551615; :j = 7b;
+		:k = NaNf; :l = -Infinity; :m = .5; :n = 3US; :o = 4u; :p = 1LL; :q = 2ull;
+		:r = 9223372036854775808; :s = -9223372036854775808;
+	}`
+	tks, errs := Tokenize(strings.NewReader(code))
+	p := Parser{Tokens: tks, Errors: errs}
+	f, err := p.Parse()
+	require.NoError(t, err)
+
+	expected := map[string]types.Value{
+		"a": types.Doubles(-1.5e10),
+		"b": types.Doubles(2.5),
+		"c": types.Floats(1.5),
+		"d": types.Shorts(-3),
+		"e": types.UBytes(200),
+		"f": types.Ints(16),
+		"g": types.Int64s(9223372036854775807),
+		"h": types.Int64s(5000000000),
+		"i": types.UInt64s(18446744073709551615),
+		"j": types.Bytes(7),
+		"l": types.Doubles(math.Inf(-1)),
+		"m": types.Doubles(0.5),
+		"n": types.UShorts(3),
+		"o": types.UInts(4),
+		"p": types.Int64s(1),
+		"q": types.UInt64s(2),
+		"r": types.UInt64s(9223372036854775808),
+		"s": types.Int64s(-9223372036854775808),
+	}
+	for name, val := range expected {
+		assert.Equal(t, val, f.Attrs.Get(name).Val, name)
+	}
+	k := f.Attrs.Get("k").Val
+	assert.Equal(t, types.Float, k.Type())
+	assert.True(t, math.IsNaN(k.Float64s()[0]))
+
+	assertParseTo(t, "netcdf fname {variables: :a = 300b;}", nil, "Parse failed: number `300b` out of range of type NC_BYTE")
+}
+
+func TestInt64Data(t *testing.T) {
+	code := `netcdf fname {dimensions: a = 2; variables: int64 v(a); data: v = -9223372036854775806, 9223372036854775807;}`
+	tks, errs := Tokenize(strings.NewReader(code))
+	p := Parser{Tokens: tks, Errors: errs}
+	f, err := p.Parse()
+	require.NoError(t, err)
+	assert.Equal(t, types.Int64s(-9223372036854775806, 9223372036854775807), f.Vars.Get("v").Data)
+}
+
+func TestAttributeLists(t *testing.T) {
+	code := `netcdf fname {dimensions: a = 1; variables:
+		double temp(a);
+		double temp:scale = 1;
+		temp:valid_range = 0, 100;
+		string temp:units = "deg", "C";
+		float :f = 1, 2.5;
+		:mixed = 1, 2.5;
+		:ints = 1b, 2s, 3;
+		:unsigned = 1ub, -1b;
+		:big = 4000000000u, -1;
+		:small = 1.5f, 2s;
+		:wide = 1.5f, 2;
+		:text = "ab", "cd";
+		short scalar;
+	}`
+	tks, errs := Tokenize(strings.NewReader(code))
+	p := Parser{Tokens: tks, Errors: errs}
+	f, err := p.Parse()
+	require.NoError(t, err)
+
+	temp := f.Vars.Get("temp")
+	assert.Equal(t, types.Doubles(1), temp.Attrs.Get("scale").Val)
+	assert.Equal(t, types.Ints(0, 100), temp.Attrs.Get("valid_range").Val)
+	assert.Equal(t, types.Text("degC"), temp.Attrs.Get("units").Val)
+
+	expected := map[string]types.Value{
+		"f":        types.Floats(1, 2.5),
+		"mixed":    types.Doubles(1, 2.5),
+		"ints":     types.Ints(1, 2, 3),
+		"unsigned": types.Shorts(1, -1),
+		"big":      types.Int64s(4000000000, -1),
+		"small":    types.Floats(1.5, 2),
+		"wide":     types.Doubles(1.5, 2),
+		"text":     types.Text("abcd"),
+	}
+	for name, val := range expected {
+		assert.Equal(t, val, f.Attrs.Get(name).Val, name)
+	}
+
+	scalar := f.Vars.Get("scalar")
+	assert.Equal(t, types.Short, scalar.Type)
+	assert.Empty(t, scalar.Dimensions)
+
+	empty, err := ParseFile("test.cdl", strings.NewReader(`netcdf fname {variables: short :e = ; double :f = ;}`))
+	require.NoError(t, err)
+	assert.Equal(t, types.Short, empty.Attrs.Get("e").Type())
+	assert.Equal(t, 0, empty.Attrs.Get("e").Val.Len())
+	assert.Equal(t, types.Double, empty.Attrs.Get("f").Type())
+	assert.Equal(t, 0, empty.Attrs.Get("f").Val.Len())
+	assertParseTo(t, `netcdf fname {variables: :e = ;}`, nil, "Parse failed: attribute value expected")
+	assertParseTo(t, `netcdf fname {variables: :a = 1, "b";}`, nil, "Parse failed: attribute `a` mixes string and numeric values")
+	assertParseTo(t, `netcdf fname {variables: double :a = "b";}`, nil, "Parse failed: string value for attribute `a` of type NC_DOUBLE")
+	assertParseTo(t, `netcdf fname {variables: string :a = 1;}`, nil, "Parse failed: numeric value for attribute `a` of type NC_CHAR")
+	assertParseTo(t, `netcdf fname {variables: byte :a = 1, 300;}`, nil, "Parse failed: invalid value for attribute `a`: Value 300 out of range of type NC_BYTE")
+	assertParseTo(t, `netcdf fname {variables: :a = 1 2;}`, nil, "Parse failed: `,` or `;` expected")
+	assertParseTo(t, `netcdf fname {variables: v:a = 1;}`, nil, "Parse failed: unknown variable `v`")
+	assertParseTo(t, `netcdf fname {variables: string v;}`, nil, "Parse failed: type `string` is not supported for variables")
+}
+
+func TestUnlimited(t *testing.T) {
+	code := `netcdf fname {
+	dimensions:
+		time = UNLIMITED ; // (12 currently)
+		x = 2 ;
+	variables:
+		float temp(time, x) ;
+	data:
+		temp = 1, 2, 3, 4, 5, 6 ;
+	}`
+	tks, errs := Tokenize(strings.NewReader(code))
+	p := Parser{Tokens: tks, Errors: errs}
+	f, err := p.Parse()
+	require.NoError(t, err)
+
+	assert.True(t, f.Dimensions[0].IsUnlimited())
+	assert.Equal(t, int64(3), f.NumRecs)
+	assert.Equal(t, "time = UNLIMITED ; // (3 currently)", CDLDimension(&f.Dimensions[0], f.NumRecs))
+
+	assertParseTo(t, "netcdf fname {dimensions: a = unlimited; b = UNLIMITED;}", nil, "Parse failed: dimension `a` is unlimited, `b` cannot be unlimited too")
+	assertParseTo(t, "netcdf fname {dimensions: a = UNLIMITED; b = 2; variables: float v(b, a);}", nil, "Parse failed: unlimited dimension `a` must be the first dimension of variable `v`")
+	assertParseTo(t, "netcdf fname {dimensions: a = b;}", nil, "Parse failed: dimension length expected")
+}
+
+func TestFormatAttribute(t *testing.T) {
+	assertParseTo(t, `netcdf fname {variables: :title = "t"; :_Format = "cdf5";}`, &types.File{
+		Version: types.CDF5,
+		Vars:    types.Vars{}.Map(),
+		Attrs: types.Attrs{{
+			Name: "title",
+			Val:  types.Text("t"),
+		}}.Map(),
+	}, "")
+
+	assertParseTo(t, `netcdf fname {variables: :_Format = "netCDF-4";}`, nil, "Parse failed: unknown format `netCDF-4`")
+	assertParseTo(t, `netcdf fname {variables: :_Format = 1;}`, nil, "Parse failed: unknown format `1`")
+}
+
+func TestDeclarationLists(t *testing.T) {
+	code := `netcdf fname {
+	dimensions:
+		lat = 10, lon = 5, time = unlimited ;
+	variables:
+		int lat(lat), lon(lon), n ;
+		float temp(time, lat, lon) ;
+	}`
+	tks, errs := Tokenize(strings.NewReader(code))
+	p := Parser{Tokens: tks, Errors: errs}
+	f, err := p.Parse()
+	require.NoError(t, err)
+
+	assert.Equal(t, []types.Dimension{{Name: "lat", Len: 10}, {Name: "lon", Len: 5}, {Name: "time", Len: 0}}, f.Dimensions)
+	assert.Equal(t, []string{"lat", "lon", "n", "temp"}, f.Vars.Keys())
+	assert.Equal(t, &f.Dimensions[1], f.Vars.Get("lon").Dimensions[0])
+	assert.Equal(t, types.Int, f.Vars.Get("n").Type)
+	assert.Empty(t, f.Vars.Get("n").Dimensions)
+
+	assertParseTo(t, "netcdf fname {dimensions: a = 1, ;}", nil, "Parse failed: dimension name expected")
+	assertParseTo(t, "netcdf fname {dimensions: a = 1 b = 2;}", nil, "Parse failed: `,` or `;` expected")
+	assertParseTo(t, "netcdf fname {dimensions: a = 1; variables: int v(a), ;}", nil, "Parse failed: variable name expected")
+	assertParseTo(t, "netcdf fname {dimensions: a = 1; variables: int v(a), w(b);}", nil, "Parse failed: unknown dimension name `b`")
+}
+
+func TestFillValueType(t *testing.T) {
+	code := `netcdf fname {dimensions: x = 2; variables:
+		short temp(x); temp:_FillValue = -999;
+		double d(x); d:_FillValue = 1;
+		char c(x); c:_FillValue = "-";
+	}`
+	f, err := ParseFile("test.cdl", strings.NewReader(code))
+	require.NoError(t, err)
+	fill := func(name string) types.Value {
+		v := f.Vars.Get(name)
+		return v.Attrs.Get("_FillValue").Val
+	}
+	assert.Equal(t, types.Shorts(-999), fill("temp"))
+	assert.Equal(t, types.Doubles(1), fill("d"))
+	assert.Equal(t, types.Text("-"), fill("c"))
+
+	assertParseTo(t, "netcdf fname {dimensions: x = 2; variables: short v(x); float v:_FillValue = 1.5;}", nil, "Parse failed: type NC_FLOAT of `v:_FillValue` differs from the type NC_SHORT of the variable")
+	assertParseTo(t, `netcdf fname {dimensions: x = 2; variables: short v(x); v:_FillValue = "a";}`, nil, "Parse failed: string value for attribute `_FillValue` of type NC_SHORT")
+}
@@ -0,0 +1,223 @@
+package cdl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Fprint writes doc to w in the canonical CDL layout:
+// sections start at the first column, declarations
+// are indented by a tab and attributes by two, with
+// the `=` of consecutive attributes aligned. Comments
+// and single empty lines between statements are kept.
+func Fprint(w io.Writer, doc *Document) error {
+	p := docPrinter{w: bufio.NewWriter(w)}
+	p.document(doc)
+	return p.w.Flush()
+}
+
+// Format parses the CDL source src and
+// returns it in the layout of Fprint.
+func Format(name string, src []byte) ([]byte, error) {
+	doc, err := ParseDocument(name, bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type docPrinter struct {
+	w *bufio.Writer
+}
+
+func (p *docPrinter) put(s ...string) {
+	for _, x := range s {
+		p.w.WriteString(x)
+	}
+}
+
+func (p *docPrinter) comments(cc []*Comment, indent string) {
+	for _, c := range cc {
+		p.put(indent, commentText(c), "\n")
+	}
+}
+
+// lineEnd ends a line, with the line comment of n if any.
+func (p *docPrinter) lineEnd(n *NodeInfo) {
+	if n.Line != nil {
+		p.put(" ", commentText(n.Line))
+	}
+	p.put("\n")
+}
+
+// header writes the empty line and
+// the doc comments that precede n.
+func (p *docPrinter) header(n *NodeInfo, indent string) {
+	if n.BlankBefore {
+		p.put("\n")
+	}
+	p.comments(n.Doc, indent)
+}
+
+func (p *docPrinter) document(doc *Document) {
+	p.comments(doc.Doc, "")
+	p.put("netcdf ", EscapeName(doc.Name), " {")
+	p.lineEnd(&doc.NodeInfo)
+	for _, s := range doc.Sections {
+		p.section(s)
+	}
+	p.comments(doc.Trailing, "\t")
+	p.put("}\n")
+	p.comments(doc.Footer, "")
+}
+
+func (p *docPrinter) section(s *Section) {
+	p.header(&s.NodeInfo, "")
+	switch s.Kind {
+	case TkDimensions:
+		p.put("dimensions:")
+	case TkVariables:
+		p.put("variables:")
+	default:
+		p.put("data:")
+	}
+	p.lineEnd(&s.NodeInfo)
+
+	widths := attrWidths(s.Stmts)
+	for i, stmt := range s.Stmts {
+		switch n := stmt.(type) {
+		case *DimDecl:
+			p.header(&n.NodeInfo, "\t")
+			specs := make([]string, len(n.Specs))
+			for i, d := range n.Specs {
+				specs[i] = EscapeName(d.Name) + " = " + d.Len
+			}
+			p.put("\t", strings.Join(specs, ", "), " ;")
+			p.lineEnd(&n.NodeInfo)
+		case *VarDecl:
+			p.header(&n.NodeInfo, "\t")
+			specs := make([]string, len(n.Specs))
+			for i, v := range n.Specs {
+				specs[i] = EscapeName(v.Name)
+				if len(v.Dims) > 0 {
+					dims := make([]string, len(v.Dims))
+					for j, d := range v.Dims {
+						dims[j] = EscapeName(d.Name)
+					}
+					specs[i] += "(" + strings.Join(dims, ", ") + ")"
+				}
+			}
+			p.put("\t", n.Type, " ", strings.Join(specs, ", "), " ;")
+			p.lineEnd(&n.NodeInfo)
+		case *AttrDecl:
+			p.header(&n.NodeInfo, "\t\t")
+			left := attrLeft(n)
+			p.put("\t\t", left, strings.Repeat(" ", widths[i]-utf8.RuneCountInString(left)), " =")
+			p.values(n.Values, "\t\t\t")
+			p.lineEnd(&n.NodeInfo)
+		case *DataStmt:
+			p.header(&n.NodeInfo, "\t")
+			p.put("\t", EscapeName(n.Var), " =")
+			p.values(n.Values, "\t\t")
+			p.lineEnd(&n.NodeInfo)
+		}
+	}
+}
+
+// values writes a list of literals and the closing `;`, keeping
+// the line breaks of the source. Wrapped lines are indented by
+// indent. When comments follow the last literal, the `;` is
+// written on a line of its own after them.
+func (p *docPrinter) values(values []*Literal, indent string) {
+	for i, v := range values {
+		broken := v.LineBreak || len(v.Doc) > 0
+		if i > 0 {
+			p.put(",")
+			if c := values[i-1].Line; c != nil {
+				p.put(" ", commentText(c))
+				broken = true
+			}
+		}
+		if broken {
+			p.put("\n")
+			p.comments(v.Doc, indent)
+			p.put(indent)
+		} else {
+			p.put(" ")
+		}
+		if v.Kind == TkStr {
+			p.put(quote(v.Text))
+		} else {
+			p.put(v.Text)
+		}
+	}
+
+	if len(values) == 0 {
+		p.put(" ;")
+		return
+	}
+	last := values[len(values)-1]
+	if last.Line == nil && len(last.Trailing) == 0 {
+		p.put(" ;")
+		return
+	}
+	if last.Line != nil {
+		p.put(" ", commentText(last.Line))
+	}
+	p.put("\n")
+	p.comments(last.Trailing, indent)
+	p.put(indent, ";")
+}
+
+// attrLeft returns the text of
+// attribute a before the `=`.
+func attrLeft(a *AttrDecl) string {
+	left := EscapeName(a.Var) + ":" + EscapeName(a.Name)
+	if a.Type != "" {
+		left = a.Type + " " + left
+	}
+	return left
+}
+
+// attrWidths returns, for each attribute in stmts, the width
+// the text before its `=` is padded to. Attributes are aligned
+// in runs of consecutive ones, not separated by empty lines.
+func attrWidths(stmts []Node) map[int]int {
+	widths := map[int]int{}
+	start := 0
+	for i := 0; i <= len(stmts); i++ {
+		var a *AttrDecl
+		if i < len(stmts) {
+			a, _ = stmts[i].(*AttrDecl)
+		}
+		if a != nil && !(a.BlankBefore && i > start) {
+			continue
+		}
+		// the run start..i ends
+		max := 0
+		for j := start; j < i; j++ {
+			if n := utf8.RuneCountInString(attrLeft(stmts[j].(*AttrDecl))); n > max {
+				max = n
+			}
+		}
+		for j := start; j < i; j++ {
+			widths[j] = max
+		}
+		start = i
+		if a == nil {
+			start = i + 1
+		}
+	}
+	return widths
+}
+
+func commentText(c *Comment) string {
+	return "//" + strings.TrimRight(c.Text, " \t\r")
+}
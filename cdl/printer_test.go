@@ -0,0 +1,153 @@
+package cdl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const unformatted = `// header comment
+netcdf   test{ // the file
+dimensions:
+  time=unlimited; // (3 currently)
+    x = 2;
+variables:
+ // temperature
+  float temp(time,x);   temp:units="K";
+      temp:long_name = "air temperature" ;
+
+  double temp:scale=0.5,2.;
+ :title =  "t\"x" ;
+data:
+ temp =
+   1,2, // first
+   3,4,
+   _,6;
+  // end
+}
+// footer
+`
+
+const formatted = `// header comment
+netcdf test { // the file
+dimensions:
+	time = UNLIMITED ; // (3 currently)
+	x = 2 ;
+variables:
+	// temperature
+	float temp(time, x) ;
+		temp:units     = "K" ;
+		temp:long_name = "air temperature" ;
+
+		double temp:scale = 0.5, 2. ;
+		:title            = "t\"x" ;
+data:
+	temp =
+		1, 2, // first
+		3, 4,
+		_, 6 ;
+	// end
+}
+// footer
+`
+
+func TestFormat(t *testing.T) {
+	out, err := Format("test.cdl", []byte(unformatted))
+	require.NoError(t, err)
+	assert.Equal(t, formatted, string(out))
+
+	// formatting is idempotent
+	again, err := Format("test.cdl", out)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, string(again))
+
+	// and doesn't change the meaning of the source
+	expected, err := ParseFile("test.cdl", strings.NewReader(unformatted))
+	require.NoError(t, err)
+	actual, err := ParseFile("test.cdl", strings.NewReader(formatted))
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestDocument(t *testing.T) {
+	doc, err := ParseDocument("test.cdl", strings.NewReader(unformatted))
+	require.NoError(t, err)
+
+	assert.Equal(t, "test", doc.Name)
+	assert.Equal(t, []*Comment{{Pos: CodePosition{CodePoint{Col: 1, Row: 1, Idx: 0}, CodePoint{Col: 17, Row: 1, Idx: 16}}, Text: " header comment"}}, doc.Doc)
+	require.Len(t, doc.Sections, 3)
+
+	dims := doc.Sections[0]
+	assert.Equal(t, TkDimensions, dims.Kind)
+	time := dims.Stmts[0].(*DimDecl)
+	assert.Equal(t, "UNLIMITED", time.Specs[0].Len)
+	assert.Equal(t, " (3 currently)", time.Line.Text)
+
+	vars := doc.Sections[1]
+	temp := vars.Stmts[0].(*VarDecl)
+	assert.Equal(t, []*Ident{{Pos: CodePosition{CodePoint{Col: 14, Row: 8, Idx: 143}, CodePoint{Col: 17, Row: 8, Idx: 146}}, Name: "time"}, {Pos: CodePosition{CodePoint{Col: 19, Row: 8, Idx: 148}, CodePoint{Col: 19, Row: 8, Idx: 148}}, Name: "x"}}, temp.Specs[0].Dims)
+	assert.Equal(t, " temperature", temp.Doc[0].Text)
+	assert.Equal(t, uint(8), temp.Pos.Start.Row)
+
+	scale := vars.Stmts[3].(*AttrDecl)
+	assert.True(t, scale.BlankBefore)
+	assert.Equal(t, "double", scale.Type)
+	assert.Equal(t, "temp", scale.Var)
+	assert.Equal(t, "2.", scale.Values[1].Text)
+
+	title := vars.Stmts[4].(*AttrDecl)
+	assert.Equal(t, "", title.Var)
+	assert.Equal(t, `t"x`, title.Values[0].Text)
+
+	data := doc.Sections[2].Stmts[0].(*DataStmt)
+	assert.Len(t, data.Values, 6)
+	assert.True(t, data.Values[0].LineBreak)
+	assert.Equal(t, " first", data.Values[1].Line.Text)
+	assert.Equal(t, TkName, data.Values[4].Kind)
+	assert.Equal(t, " end", doc.Trailing[0].Text)
+	assert.Equal(t, " footer", doc.Footer[0].Text)
+}
+
+func TestFormatComments(t *testing.T) {
+	// comments stay next to the values they follow or precede
+	cases := map[string]string{
+		"netcdf x {\ndata:\n v = 1, // one\n // two\n 2 // two\n ; // end\n}": "netcdf x {\ndata:\n\tv = 1, // one\n\t\t// two\n\t\t2 // two\n\t\t; // end\n}\n",
+		"netcdf x {\ndata:\n v = 1 // one\n , 2\n // after\n ;\n}":            "netcdf x {\ndata:\n\tv = 1, // one\n\t\t2\n\t\t// after\n\t\t;\n}\n",
+		"netcdf x {\nvariables:\n :a = \"s\" // s\n ;\n}":                     "netcdf x {\nvariables:\n\t\t:a = \"s\" // s\n\t\t\t;\n}\n",
+	}
+	for src, expected := range cases {
+		out, err := Format("test.cdl", []byte(src))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(out), src)
+
+		again, err := Format("test.cdl", out)
+		require.NoError(t, err)
+		assert.Equal(t, string(out), string(again), src)
+	}
+}
+
+func TestFormatDeclarationLists(t *testing.T) {
+	out, err := Format("test.cdl", []byte("netcdf x {\ndimensions:\n lat=10,lon = 5 ,time=unlimited;\nvariables:\n int lat(lat),lon( lon ),n;\n}"))
+	require.NoError(t, err)
+	assert.Equal(t, "netcdf x {\ndimensions:\n\tlat = 10, lon = 5, time = UNLIMITED ;\nvariables:\n\tint lat(lat), lon(lon), n ;\n}\n", string(out))
+}
+
+func TestFormatEmptyAttribute(t *testing.T) {
+	out, err := Format("test.cdl", []byte("netcdf x {\nvariables:\n short :e=;\n}"))
+	require.NoError(t, err)
+	assert.Equal(t, "netcdf x {\nvariables:\n\t\tshort :e = ;\n}\n", string(out))
+}
+
+func TestFormatErrors(t *testing.T) {
+	_, err := Format("test.cdl", []byte("netcdf x {\ndimensions:\n\ta = ;\n}"))
+	var diags Diagnostics
+	require.True(t, errors.As(err, &diags))
+	assert.Equal(t, "test.cdl:3:6: dimension length expected (found `;`)\n\ta = ;\n\t    ^", diags.String())
+
+	_, err = Format("test.cdl", []byte("netcdf x {\ndimensions:\n\ta = 1.2.3 ;\n}"))
+	require.True(t, errors.As(err, &diags))
+	assert.Equal(t, "test.cdl:3:9: unexpected dot\n\ta = 1.2.3 ;\n\t       ^", diags.String())
+}
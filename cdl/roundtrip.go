@@ -0,0 +1,251 @@
+package cdl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/parro-it/ncdf/ordmap"
+	"github.com/parro-it/ncdf/read"
+	"github.com/parro-it/ncdf/types"
+	"github.com/parro-it/ncdf/write"
+)
+
+// Difference is a difference between two files, found by Compare.
+type Difference struct {
+	// Path locates the difference, e.g. "format",
+	// "attribute `temp:units`" or "data of `temp` at index 3"
+	Path string
+	// A and B describe the element in the two files
+	A, B string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.Path, d.A, d.B)
+}
+
+// Compare returns the differences between file a, with
+// values read from fda, and file b, with values read from
+// fdb. Types, values of attributes and of variables and
+// the format of the files are compared. NaN values are
+// equal to each other, and attributes can be defined in
+// any order. Values of variables are read a chunk at a
+// time, and compared only when their shapes are the same.
+func Compare(a *types.File, fda io.ReadSeeker, b *types.File, fdb io.ReadSeeker) ([]Difference, error) {
+	var diffs []Difference
+	diff := func(path string, x, y interface{}) {
+		diffs = append(diffs, Difference{Path: path, A: fmt.Sprint(x), B: fmt.Sprint(y)})
+	}
+
+	if a.Version.OrDefault() != b.Version.OrDefault() {
+		diff("format", FormatName(a.Version), FormatName(b.Version))
+	}
+	if a.NumRecs != b.NumRecs {
+		diff("number of records", a.NumRecs, b.NumRecs)
+	}
+
+	if len(a.Dimensions) != len(b.Dimensions) {
+		diff("number of dimensions", len(a.Dimensions), len(b.Dimensions))
+	}
+	for i := 0; i < len(a.Dimensions) && i < len(b.Dimensions); i++ {
+		da, db := a.Dimensions[i], b.Dimensions[i]
+		if da != db {
+			diff(fmt.Sprintf("dimension %d", i), dimensionString(da), dimensionString(db))
+		}
+	}
+
+	diffs = append(diffs, compareAttrs("", a.Attrs, b.Attrs)...)
+
+	for _, va := range a.Vars.Values() {
+		path := fmt.Sprintf("variable `%s`", va.Name)
+		if !b.Vars.Has(va.Name) {
+			diff(path, "defined", "missing")
+			continue
+		}
+		vb := b.Vars.Get(va.Name)
+		if va.Type != vb.Type {
+			diff("type of "+path, va.Type.CDLName(), vb.Type.CDLName())
+		}
+		if dimensionNames(va) != dimensionNames(vb) {
+			diff("dimensions of "+path, dimensionNames(va), dimensionNames(vb))
+		}
+		diffs = append(diffs, compareAttrs(va.Name, va.Attrs, vb.Attrs)...)
+
+		wa, wb := a.WholeSlab(va), b.WholeSlab(vb)
+		if va.Type != vb.Type || fmt.Sprint(wa.Count) != fmt.Sprint(wb.Count) {
+			continue
+		}
+		d, err := compareData(a, va, fda, b, vb, fdb)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			diffs = append(diffs, *d)
+		}
+	}
+	for _, vb := range b.Vars.Values() {
+		if !a.Vars.Has(vb.Name) {
+			diff(fmt.Sprintf("variable `%s`", vb.Name), "missing", "defined")
+		}
+	}
+	return diffs, nil
+}
+
+// RoundTrip writes f, with values read from fd, in CDL,
+// parses it back with Parser, and writes the result in
+// the format of f. It returns the differences between
+// f and the resulting file, found by Compare: no
+// differences means that f survives the trip losslessly.
+// The CDL text and the new file are kept in memory.
+func RoundTrip(f *types.File, fd io.ReadSeeker) ([]Difference, error) {
+	var text bytes.Buffer
+	if err := FormatFile(&text, f, fd, FormatOptions{Format: true}); err != nil {
+		return nil, err
+	}
+	parsed, err := ParseFile("roundtrip.cdl", &text)
+	if err != nil {
+		return nil, err
+	}
+	parsed.ComputeSizes()
+
+	var out buffer
+	if err := write.Header(parsed, &out); err != nil {
+		return nil, err
+	}
+	if err := write.Fill(parsed, &out); err != nil {
+		return nil, err
+	}
+	if err := write.Data(parsed, &out); err != nil {
+		return nil, err
+	}
+
+	outFd := bytes.NewReader(out.Bytes())
+	res, err := read.Header(outFd)
+	if err != nil {
+		return nil, err
+	}
+	return Compare(f, fd, res, outFd)
+}
+
+// compareAttrs returns the differences between attributes
+// a and b of variable varName, or global if it's empty.
+func compareAttrs(varName string, a, b ordmap.OrderedMap[types.Attr, string]) []Difference {
+	var diffs []Difference
+	path := func(name string) string {
+		return fmt.Sprintf("attribute `%s:%s`", varName, name)
+	}
+	for _, aa := range a.Values() {
+		if !b.Has(aa.Name) {
+			diffs = append(diffs, Difference{Path: path(aa.Name), A: "defined", B: "missing"})
+			continue
+		}
+		ab := b.Get(aa.Name)
+		if !aa.Val.Equal(ab.Val) {
+			diffs = append(diffs, Difference{Path: path(aa.Name), A: attrString(aa.Val), B: attrString(ab.Val)})
+		}
+	}
+	for _, ab := range b.Values() {
+		if !a.Has(ab.Name) {
+			diffs = append(diffs, Difference{Path: path(ab.Name), A: "missing", B: "defined"})
+		}
+	}
+	return diffs
+}
+
+// compareData returns the first difference between
+// values of variable va of file a and vb of file b,
+// that have the same shape, or nil when they are equal.
+func compareData(a *types.File, va types.Var, fda io.ReadSeeker, b *types.File, vb types.Var, fdb io.ReadSeeker) (*Difference, error) {
+	start := 0
+	for _, s := range chunkSlabs(a, va) {
+		x, err := readSlab(a, va, s, fda)
+		if err != nil {
+			return nil, err
+		}
+		y, err := readSlab(b, vb, s, fdb)
+		if err != nil {
+			return nil, err
+		}
+		if !x.Equal(y) {
+			i := firstDifference(x, y)
+			return &Difference{
+				Path: fmt.Sprintf("data of `%s` at index %d", va.Name, start+i),
+				A:    elementString(x, i),
+				B:    elementString(y, i),
+			}, nil
+		}
+		start += x.Len()
+	}
+	return nil, nil
+}
+
+// firstDifference returns the index of the first value
+// that differs in a and b, of the same type and length.
+func firstDifference(a, b types.Value) int {
+	switch a.Type() {
+	case types.Char:
+		sa, sb := a.String(), b.String()
+		for i := range sa {
+			if sa[i] != sb[i] {
+				return i
+			}
+		}
+	case types.Float, types.Double:
+		fa, fb := a.Float64s(), b.Float64s()
+		for i := range fa {
+			if fa[i] != fb[i] && !(math.IsNaN(fa[i]) && math.IsNaN(fb[i])) {
+				return i
+			}
+		}
+	default:
+		sa, sb := formatValues(a, 0), formatValues(b, 0)
+		for i := range sa {
+			if sa[i] != sb[i] {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// elementString returns the CDL literal
+// of the value at index i of val.
+func elementString(val types.Value, i int) string {
+	if val.Type() == types.Char {
+		return quote(val.String()[i : i+1])
+	}
+	return formatValues(val, 0)[i]
+}
+
+func attrString(val types.Value) string {
+	return val.Type().CDLName() + " " + formatAttr(val, 0)
+}
+
+func dimensionString(d types.Dimension) string {
+	if d.IsUnlimited() {
+		return EscapeName(d.Name) + " = UNLIMITED"
+	}
+	return fmt.Sprintf("%s = %d", EscapeName(d.Name), d.Len)
+}
+
+func dimensionNames(v types.Var) string {
+	names := make([]string, len(v.Dimensions))
+	for i, d := range v.Dimensions {
+		names[i] = EscapeName(d.Name)
+	}
+	return "(" + strings.Join(names, ", ") + ")"
+}
+
+// buffer is an in memory io.WriterAt
+type buffer struct {
+	bytes.Buffer
+}
+
+func (w *buffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > w.Len() {
+		w.Write(make([]byte, end-w.Len()))
+	}
+	return copy(w.Bytes()[off:], p), nil
+}
@@ -0,0 +1,172 @@
+package cdl
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/parro-it/ncdf/read"
+	"github.com/parro-it/ncdf/types"
+	"github.com/parro-it/ncdf/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generate writes the file described by code in
+// memory, and returns its header read back.
+func generate(t *testing.T, code string) (*types.File, *bytes.Reader) {
+	f, err := ParseFile("test.cdl", strings.NewReader(code))
+	require.NoError(t, err)
+	f.ComputeSizes()
+	var buf buffer
+	require.NoError(t, write.Header(f, &buf))
+	require.NoError(t, write.Fill(f, &buf))
+	require.NoError(t, write.Data(f, &buf))
+	fd := bytes.NewReader(buf.Bytes())
+	res, err := read.Header(fd)
+	require.NoError(t, err)
+	return res, fd
+}
+
+func TestRoundTrip(t *testing.T) {
+	f, fd := dumpFile(t)
+	diffs, err := RoundTrip(f, fd)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	negZero32 := float32(math.Copysign(0, -1))
+	nan32 := float32(math.NaN())
+	dims := []types.Dimension{{Name: "rec", Len: 0}, {Name: "x y", Len: 2}}
+	f = (&types.File{
+		Version:    types.CDF5,
+		Dimensions: dims,
+		Attrs: types.Attrs{
+			{Name: "b", Val: types.Bytes(1, 255)},
+			{Name: "s", Val: types.Shorts(-3)},
+			{Name: "i", Val: types.Ints(7)},
+			{Name: "f", Val: types.Floats(nan32, float32(math.Inf(1)), 1e-45, negZero32, 2)},
+			{Name: "d", Val: types.Doubles(math.NaN(), math.Inf(-1), 5e-324, 1.0/3, 2)},
+			{Name: "ub", Val: types.UBytes(255)},
+			{Name: "us", Val: types.UShorts(math.MaxUint16)},
+			{Name: "ui", Val: types.UInts(math.MaxUint32)},
+			{Name: "l", Val: types.Int64s(math.MinInt64)},
+			{Name: "ul", Val: types.UInt64s(math.MaxUint64)},
+			{Name: "c", Val: types.Text("x\x00y\"\n")},
+			{Name: "e", Val: types.Shorts()},
+			{Name: "empty", Val: types.Text("")},
+		}.Map(),
+		Vars: types.Vars{
+			{Name: "f", Type: types.Float, Dimensions: []*types.Dimension{&dims[0], &dims[1]}, Attrs: types.Attrs{
+				{Name: "_FillValue", Val: types.Floats(nan32)},
+				{Name: "none", Val: types.Doubles()},
+			}.Map()},
+			{Name: "int", Type: types.Int64, Dimensions: []*types.Dimension{&dims[1]}},
+			{Name: "c", Type: types.Char, Dimensions: []*types.Dimension{&dims[0]}},
+			{Name: "u", Type: types.UByte},
+			{Name: "d", Type: types.Double, Dimensions: []*types.Dimension{&dims[1]}},
+		}.Map(),
+	}).ComputeSizes()
+	var buf buffer
+	require.NoError(t, write.Header(f, &buf))
+	require.NoError(t, write.Fill(f, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("f"), []float32{negZero32, nan32, float32(math.Inf(-1)), 0.1}, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("int"), []int64{math.MaxInt64, -1}, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("c"), []byte("a\x00"), &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("d"), []float64{math.Copysign(0, -1), math.MaxFloat64}, &buf))
+
+	diffs, err = RoundTrip(f, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	// files without variables
+	for _, code := range []string{
+		"netcdf a {}",
+		"netcdf a {dimensions: x = 2;}",
+		`netcdf a {variables: :title = "t";}`,
+		`netcdf a {dimensions: x = 2; variables: :title = "t"; :_Format = "classic";}`,
+	} {
+		f, fd := generate(t, code)
+		diffs, err := RoundTrip(f, fd)
+		require.NoError(t, err, code)
+		assert.Empty(t, diffs, code)
+	}
+}
+
+func TestRoundTripBinaryText(t *testing.T) {
+	dims := []types.Dimension{{Name: "x", Len: 2}, {Name: "len", Len: 3}}
+	f := (&types.File{
+		Version:    types.CDF1,
+		Dimensions: dims,
+		Attrs: types.Attrs{
+			{Name: "latin1", Val: types.Text("caf\xe9")},
+			{Name: "utf8", Val: types.Text("café")},
+		}.Map(),
+		Vars: types.Vars{
+			{Name: "c", Type: types.Char, Dimensions: []*types.Dimension{&dims[0], &dims[1]}},
+		}.Map(),
+	}).ComputeSizes()
+	var buf buffer
+	require.NoError(t, write.Header(f, &buf))
+	// the second row splits the UTF-8 sequence of é
+	require.NoError(t, write.VarData(f, f.Vars.Get("c"), []byte("\xe9\xff\x80ab\xc3"), &buf))
+
+	var out bytes.Buffer
+	require.NoError(t, FormatFile(&out, f, bytes.NewReader(buf.Bytes()), FormatOptions{}))
+	assert.Contains(t, out.String(), `:latin1 = "caf\xe9" ;`)
+	assert.Contains(t, out.String(), `:utf8 = "café" ;`)
+	assert.Contains(t, out.String(), `"\xe9\xff\x80",`)
+	assert.Contains(t, out.String(), `"ab\xc3" ;`)
+
+	diffs, err := RoundTrip(f, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestCompare(t *testing.T) {
+	a, fda := generate(t, `netcdf a {
+	dimensions:
+		x = 3 ;
+	variables:
+		short v(x) ;
+			v:scale = 1s ;
+		float w(x) ;
+		int only_a ;
+		:title = "a" ;
+	data:
+		v = 1, 2, 3 ;
+		w = 1, NaN, 3 ;
+	}`)
+	b, fdb := generate(t, `netcdf b {
+	dimensions:
+		x = 3 ;
+	variables:
+		short v(x) ;
+			v:scale = 1 ;
+		float w(x) ;
+		int only_b ;
+		:title = "a" ;
+		:_Format = "cdf5" ;
+	data:
+		v = 1, 2, 4 ;
+		w = 1, NaN, 3 ;
+	}`)
+
+	diffs, err := Compare(a, fda, a, fda)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	diffs, err = Compare(a, fda, b, fdb)
+	require.NoError(t, err)
+	strs := make([]string, len(diffs))
+	for i, d := range diffs {
+		strs[i] = d.String()
+	}
+	assert.Equal(t, []string{
+		"format: 64-bit offset != cdf5",
+		"attribute `v:scale`: short 1s != int 1",
+		"data of `v` at index 2: 3 != 4",
+		"variable `only_a`: defined != missing",
+		"variable `only_b`: missing != defined",
+	}, strs)
+}
@@ -2,12 +2,13 @@ package cdl
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/parro-it/ncdf/types"
 )
 
 // TokenType describe the type of tokens
@@ -21,7 +22,8 @@ const (
 	// TkName represents the name of variables, dimensions etc.
 	TkName
 
-	// TkVarType represents the type of variables (byte,short,int,char,float,double)
+	// TkVarType represents the type of variables (byte,short,int,char,float,double,
+	// ubyte,ushort,uint,int64,uint64) or of attributes, that can be string too
 	TkVarType
 
 	// TkCurOpen - { char
@@ -48,7 +50,8 @@ const (
 	// TkComma - , char
 	TkComma
 
-	// TkComment ...
+	// TkComment - a // comment, returned
+	// only by lexers that keep comments
 	TkComment
 
 	// TkNetCdf - netcdf string
@@ -69,8 +72,9 @@ const (
 	TkDec
 )
 
-// CodePoint represent a single point in a source file,
-// either with column/index numer of character index.
+// CodePoint represent a single point in a source file:
+// Row and Col start from 1, and Col counts characters,
+// while Idx is the offset in bytes from the start of file.
 type CodePoint struct {
 	Col uint
 	Row uint
@@ -91,113 +95,207 @@ type Token struct {
 	Type   TokenType
 	Text   string
 	NumVal float64
+	// NumType is the type selected by the suffix
+	// of numeric literals, Unknown without suffix.
+	NumType types.Type
 }
 
 func (t Token) String() string {
 	return fmt.Sprintf("[%d,%s]", t.Type, t.Text)
 }
 
-type tokenizer struct {
-	r     *bufio.Reader
-	res   chan Token
-	curr  rune
-	atEnd bool
-
+// Lexer reads the tokens of a CDL source, one at a time.
+type Lexer struct {
+	// Comments makes Next return comments as TkComment
+	// tokens, with the text following `//`. They are
+	// skipped otherwise.
+	Comments bool
+
+	r       *bufio.Reader
+	curr    rune
+	atEnd   bool
+	started bool
+	err     error
+
+	// curpos is the position of curr, prev the
+	// position of the character read before it, and
+	// next the position of the character after it.
 	curpos CodePoint
+	prev   CodePoint
+	next   CodePoint
 }
 
-// Tokenize ...
-func Tokenize(r io.Reader) (ch chan Token, errs chan error) {
-	errs = make(chan error)
+// NewLexer returns a Lexer that reads the CDL source from r.
+func NewLexer(r io.Reader) *Lexer {
+	return &Lexer{
+		r:    bufio.NewReader(r),
+		next: CodePoint{Col: 1, Row: 1},
+	}
+}
+
+// Next returns the next token of the source. At the end of
+// the source, it returns a token of type TkEmpty. Errors
+// wrap a *Diagnostic with their position; after an error,
+// Next keeps returning it.
+func (tkn *Lexer) Next() (tk Token, err error) {
+	if tkn.err != nil {
+		return Token{}, tkn.err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			var msg string
+			switch e := r.(type) {
+			case error:
+				msg = e.Error()
+			default:
+				msg = fmt.Sprint(r)
+			}
+			tkn.err = fmt.Errorf("Tokenization failed: %w", &Diagnostic{
+				Pos: tkn.curpos,
+				Msg: msg,
+			})
+			tk, err = Token{}, tkn.err
+		}
+	}()
 
-	tkn := tokenizer{
-		r:   bufio.NewReader(r),
-		res: make(chan Token),
+	if !tkn.started {
+		tkn.started = true
+		tkn.readRune()
 	}
+	return tkn.scan(), nil
+}
+
+// Tokenize returns a channel of the tokens read from r,
+// closed at the end of the source or after an error,
+// that is then sent on errs. Tokens are read by a
+// goroutine that blocks until all of them are received:
+// prefer a Lexer, that has no such cost.
+func Tokenize(r io.Reader) (ch chan Token, errs chan error) {
+	ch = make(chan Token)
+	errs = make(chan error)
+	l := NewLexer(r)
 
 	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				close(tkn.res)
-				var err error
-				switch e := r.(type) {
-				case error:
-					err = e
-				default:
-					s := fmt.Sprint(r)
-					err = errors.New(s)
-				}
-				errs <- fmt.Errorf("Tokenization failed: %w", err)
+		defer close(errs)
+		for {
+			tk, err := l.Next()
+			if err != nil {
+				close(ch)
+				errs <- err
+				return
 			}
-			close(errs)
-		}()
-
-		tkn.read()
+			if tk.Type == TkEmpty {
+				close(ch)
+				return
+			}
+			ch <- tk
+		}
 	}()
 
-	return tkn.res, errs
+	return ch, errs
+}
 
+// keywords maps the reserved words of CDL to their token type.
+var keywords = map[string]TokenType{
+	"byte": TkVarType, "short": TkVarType, "int": TkVarType,
+	"float": TkVarType, "double": TkVarType, "char": TkVarType,
+	"ubyte": TkVarType, "ushort": TkVarType, "uint": TkVarType,
+	"int64": TkVarType, "uint64": TkVarType, "string": TkVarType,
+	"netcdf":     TkNetCdf,
+	"dimensions": TkDimensions,
+	"variables":  TkVariables,
+	"data":       TkData,
 }
 
-// TODO: name must support special charaters aftere first letter
-func (tkn *tokenizer) readName() {
+// readName reads an identifier or a keyword. Names start
+// with a letter, an underscore or a UTF-8 character, and
+// continue with those, digits and `-`, `+`, `.` or `@`.
+// Any character, like a leading digit, can be part of a
+// name when escaped with a backslash. Escaped names are
+// never keywords.
+func (tkn *Lexer) readName() Token {
+	start := tkn.curpos
 	var buf strings.Builder
-	for !tkn.atEnd && (unicode.IsLetter(tkn.curr) || unicode.IsDigit(tkn.curr)) {
+	escaped := false
+	for !tkn.atEnd {
+		if tkn.curr == '\\' {
+			tkn.readRune()
+			if tkn.atEnd {
+				panic("unexpected end of file after `\\`")
+			}
+			escaped = true
+		} else if buf.Len() == 0 && !isNameStart(tkn.curr) || buf.Len() > 0 && !isNameChar(tkn.curr) {
+			break
+		}
 		buf.WriteRune(tkn.curr)
 		tkn.readRune()
 	}
 	val := buf.String()
-	var tkType TokenType
-	switch val {
-	case "byte", "short", "int", "float", "double", "char":
-		tkType = TkVarType
-	case "netcdf":
-		tkType = TkNetCdf
-	case "dimensions":
-		tkType = TkDimensions
-	case "variables":
-		tkType = TkVariables
-	case "data":
-		tkType = TkData
-	default:
+	if escaped {
+		return tkn.emit(Token{Type: TkName, Text: val}, start)
+	}
+	if _, ok := specialFloats[val]; ok {
+		tk, _ := numberToken(val)
+		return tkn.emit(tk, start)
+	}
+	tkType, ok := keywords[val]
+	if !ok {
 		tkType = TkName
 	}
-	tkn.res <- Token{
+	return tkn.emit(Token{
 		Type: tkType,
 		Text: val,
-	}
+	}, start)
+}
+
+// emit returns tk, setting its position from
+// start to the last character read.
+func (tkn *Lexer) emit(tk Token, start CodePoint) Token {
+	tk.Pos = CodePosition{Start: start, End: tkn.prev}
+	return tk
 }
 
-func (tkn *tokenizer) skipComment() {
+// readComment reads a comment up to the end of line,
+// and returns it as a TkComment token.
+func (tkn *Lexer) readComment() Token {
+	start := tkn.curpos
 	tkn.readRune()
 	if tkn.curr != '/' {
 		panic("unexpected char `/`")
 	}
 
 	tkn.readRune()
+	var text strings.Builder
 	for !tkn.atEnd && tkn.curr != '\n' {
+		text.WriteRune(tkn.curr)
 		tkn.readRune()
 	}
+	return tkn.emit(Token{Type: TkComment, Text: text.String()}, start)
 }
 
-func (tkn *tokenizer) read() {
-	tkn.readRune()
+// scan returns the token that starts at the current
+// character or after it, or a TkEmpty token at the end.
+func (tkn *Lexer) scan() Token {
 	for !tkn.atEnd {
-		//fmt.Printf("%c %t\n", tkn.curr, unicode.IsSymbol(tkn.curr) || unicode.IsPunct(tkn.curr))
 		switch true {
 		case tkn.curr == '/':
-			tkn.skipComment()
+			if tk := tkn.readComment(); tkn.Comments {
+				return tk
+			}
 		case tkn.curr == '"':
-			tkn.readString()
+			return tkn.readString()
 		case unicode.IsDigit(tkn.curr):
-			tkn.readNumber()
+			return tkn.readNumber()
+		case (tkn.curr == '-' || tkn.curr == '+') && isNumStart(tkn.peek()),
+			tkn.curr == '.' && unicode.IsDigit(tkn.peek()):
+			return tkn.readNumber()
 
-		case unicode.IsLetter(tkn.curr):
-			tkn.readName()
+		case isNameStart(tkn.curr) || tkn.curr == '\\':
+			return tkn.readName()
 		default:
 			tk := Token{
 				Pos:  CodePosition{tkn.curpos, tkn.curpos},
-				Text: string([]rune{tkn.curr}),
+				Text: string(tkn.curr),
 			}
 			// single char tokens
 			switch tkn.curr {
@@ -217,46 +315,75 @@ func (tkn *tokenizer) read() {
 				tk.Type = TkSemicolon
 			case ',':
 				tk.Type = TkComma
+			default:
+				if !unicode.IsSpace(tkn.curr) {
+					panic(fmt.Sprintf("unexpected character `%c`", tkn.curr))
+				}
 			}
 
+			tkn.readRune()
 			if tk.Type != TkEmpty {
-				tkn.res <- tk
+				return tk
 			}
-
-			tkn.readRune()
 		}
 	}
-	close(tkn.res)
+	return Token{}
 }
 
-// TODO: parse negative numbers
-func (tkn *tokenizer) readNumber() {
-	tokType := TkInt
-	text := ""
-	for !tkn.atEnd && isNumChar(tkn.curr) {
-		if tkn.curr == '.' {
-			if tokType == TkInt {
-				tokType = TkDec
-			} else {
+// readNumber reads a numeric literal, including
+// its sign and type suffix, see numberToken.
+func (tkn *Lexer) readNumber() Token {
+	start := tkn.curpos
+	var text strings.Builder
+	if tkn.curr == '-' || tkn.curr == '+' {
+		text.WriteRune(tkn.curr)
+		tkn.readRune()
+	}
+	dots := 0
+	hex := false
+	var last rune
+	for !tkn.atEnd {
+		c := tkn.curr
+		exponent := !hex && (last == 'e' || last == 'E')
+		if !isNumChar(c) && !unicode.IsLetter(c) && !(exponent && (c == '+' || c == '-')) {
+			break
+		}
+		if c == 'x' || c == 'X' {
+			hex = true
+		}
+		last = c
+		if c == '.' {
+			dots++
+			if dots > 1 {
 				panic("unexpected dot")
 			}
 		}
-		text += string(tkn.curr)
+		text.WriteRune(c)
 		tkn.readRune()
 	}
-	// TODO: parse integers with dedicated func
-	num, _ := strconv.ParseFloat(text, 64)
-
-	tkn.res <- Token{
-		Type: tokType,
-		// TODO: use different field for integers
-		NumVal: num,
-		Text:   text,
+
+	tk, err := numberToken(text.String())
+	if err != nil {
+		panic(err)
 	}
+	return tkn.emit(tk, start)
 }
 
-func (tkn *tokenizer) readRune() {
+// peek returns the rune following the current one,
+// without consuming it.
+func (tkn *Lexer) peek() rune {
 	r, _, err := tkn.r.ReadRune()
+	if err != nil {
+		return rune(0)
+	}
+	tkn.r.UnreadRune()
+	return r
+}
+
+func (tkn *Lexer) readRune() {
+	tkn.prev = tkn.curpos
+	tkn.curpos = tkn.next
+	r, size, err := tkn.r.ReadRune()
 	if err == io.EOF {
 		tkn.curr = rune(0)
 		tkn.atEnd = true
@@ -267,19 +394,86 @@ func (tkn *tokenizer) readRune() {
 	}
 
 	tkn.curr = r
+	tkn.next.Idx += uint(size)
+	if r == '\n' {
+		tkn.next.Row++
+		tkn.next.Col = 1
+	} else {
+		tkn.next.Col++
+	}
 }
 
 func isNumChar(ch rune) bool {
 	return unicode.IsDigit(ch) || ch == '.'
 }
 
-func (tkn *tokenizer) readString() {
-	text := ""
+// isNumStart returns whether ch can follow the sign
+// or the leading dot of a numeric literal.
+func isNumStart(ch rune) bool {
+	return isNumChar(ch) || ch == 'I' || ch == 'N'
+}
+
+// isNameStart returns whether ch can start a name
+// without being escaped.
+func isNameStart(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_' || ch >= utf8.RuneSelf
+}
+
+// isNameChar returns whether ch can be part of
+// a name, after its first character, without
+// being escaped.
+func isNameChar(ch rune) bool {
+	return isNameStart(ch) || unicode.IsDigit(ch) || strings.ContainsRune("-+.@", ch)
+}
+
+// stringEscapes maps the characters that follow a
+// backslash in string literals to the escaped character.
+var stringEscapes = map[rune]rune{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r',
+	't': '\t', 'v': '\v', '\\': '\\', '"': '"', '\'': '\'', '?': '?',
+}
+
+// readString reads a string literal. Backslash escapes
+// are the ones of C: single characters, up to three
+// octal digits or `\x` followed by hex digits.
+func (tkn *Lexer) readString() Token {
+	start := tkn.curpos
+	var text strings.Builder
 	tkn.readRune()
 
 	for !tkn.atEnd && tkn.curr != '"' {
-		text += string(tkn.curr)
+		if tkn.curr != '\\' {
+			text.WriteRune(tkn.curr)
+			tkn.readRune()
+			continue
+		}
 		tkn.readRune()
+		if tkn.atEnd {
+			break
+		}
+		if c, ok := stringEscapes[tkn.curr]; ok {
+			text.WriteRune(c)
+			tkn.readRune()
+			continue
+		}
+		base, maxDigits := 8, 3
+		if tkn.curr == 'x' {
+			base, maxDigits = 16, 2
+			tkn.readRune()
+		}
+		code, digits := 0, 0
+		for ; digits < maxDigits && !tkn.atEnd; digits++ {
+			d := digitValue(tkn.curr)
+			if d < 0 || d >= base {
+				break
+			}
+			code = code*base + d
+			tkn.readRune()
+		}
+		if digits == 0 {
+			panic(fmt.Sprintf("invalid escape sequence `\\%c` in string", tkn.curr))
+		}
+		text.WriteByte(byte(code))
 	}
 
 	if tkn.curr != '"' {
@@ -287,8 +481,22 @@ func (tkn *tokenizer) readString() {
 	}
 	tkn.readRune()
 
-	tkn.res <- Token{
+	return tkn.emit(Token{
 		Type: TkStr,
-		Text: text,
+		Text: text.String(),
+	}, start)
+}
+
+// digitValue returns the value of hex digit
+// ch, or -1 if ch is not a digit.
+func digitValue(ch rune) int {
+	switch {
+	case ch >= '0' && ch <= '9':
+		return int(ch - '0')
+	case ch >= 'a' && ch <= 'f':
+		return int(ch-'a') + 10
+	case ch >= 'A' && ch <= 'F':
+		return int(ch-'A') + 10
 	}
+	return -1
 }
@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/parro-it/ncdf/types"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -21,6 +23,10 @@ func assertTokenizeTo(t *testing.T, name, code string, expected ...interface{})
 		for _, extk := range expected {
 			select {
 			case tk := <-tks:
+				// positions are checked only when expected
+				if ex, ok := extk.(Token); ok && ex.Pos == (CodePosition{}) {
+					tk.Pos = CodePosition{}
+				}
 				assert.Equal(t, extk, tk)
 			case <-time.After(100 * time.Millisecond):
 				assert.FailNowf(t, "FAILURE", "Expecting %v but found closed chan.", extk)
@@ -95,6 +101,22 @@ func TestTokenize(t *testing.T) {
 		Type: TkVarType,
 		Text: "char",
 	})
+	assertTokenizeTo(t, "CDF-5 reserved words", `ubyte ushort uint int64 uint64`, Token{
+		Type: TkVarType,
+		Text: "ubyte",
+	}, Token{
+		Type: TkVarType,
+		Text: "ushort",
+	}, Token{
+		Type: TkVarType,
+		Text: "uint",
+	}, Token{
+		Type: TkVarType,
+		Text: "int64",
+	}, Token{
+		Type: TkVarType,
+		Text: "uint64",
+	})
 	assertTokenizeTo(t, "netcdf", `netcdf`, Token{
 		Type: TkNetCdf,
 		Text: "netcdf",
@@ -157,6 +179,36 @@ func TestTokenize(t *testing.T) {
 
 	})
 
+	assertTokenizeTo(t, "negative exponent", "-1.5e-10d", Token{
+		Type:    TkDec,
+		Text:    "-1.5e-10d",
+		NumVal:  -1.5e-10,
+		NumType: types.Double,
+	})
+
+	assertTokenizeTo(t, "hex with suffix", "0xffs", Token{
+		Type:    TkInt,
+		Text:    "0xffs",
+		NumVal:  255,
+		NumType: types.Short,
+	})
+
+	assertTokenizeTo(t, "integer with float suffix", "1f,", Token{
+		Type:    TkDec,
+		Text:    "1f",
+		NumVal:  1,
+		NumType: types.Float,
+	}, Token{
+		Type: TkComma,
+		Text: ",",
+	})
+
+	t.Run("invalid suffix", func(t *testing.T) {
+		tks, err := Tokenize(strings.NewReader("1.5s"))
+		assert.Empty(t, <-tks)
+		assert.EqualError(t, <-err, "Tokenization failed: invalid suffix `s` of decimal number `1.5s`")
+	})
+
 	t.Run("unclosed string", func(t *testing.T) {
 		tks, err := Tokenize(strings.NewReader(`"ciao`))
 		assert.Empty(t, <-tks)
@@ -165,4 +217,159 @@ func TestTokenize(t *testing.T) {
 
 	})
 
+	t.Run("unexpected character", func(t *testing.T) {
+		for _, c := range []string{"$", "#", "!", "?", "@"} {
+			tks, err := Tokenize(strings.NewReader(" \t" + c))
+			assert.Empty(t, <-tks)
+			assert.EqualError(t, <-err, "Tokenization failed: unexpected character `"+c+"`")
+		}
+	})
+
+}
+
+func TestNamesAndStrings(t *testing.T) {
+	assertTokenizeTo(t, "special chars", "air_temperature sea-level a.b+c@d", Token{
+		Type: TkName,
+		Text: "air_temperature",
+	}, Token{
+		Type: TkName,
+		Text: "sea-level",
+	}, Token{
+		Type: TkName,
+		Text: "a.b+c@d",
+	})
+	assertTokenizeTo(t, "escapes", `\2m_temp a\ b \data`, Token{
+		Type: TkName,
+		Text: "2m_temp",
+	}, Token{
+		Type: TkName,
+		Text: "a b",
+	}, Token{
+		Type: TkName,
+		Text: "data",
+	})
+	assertTokenizeTo(t, "utf8", "température", Token{
+		Type: TkName,
+		Text: "température",
+	})
+	assertTokenizeTo(t, "string escapes", `"a\"b\\c\n\td\0\101\x42"`, Token{
+		Type: TkStr,
+		Text: "a\"b\\c\n\td\x00AB",
+	})
+
+	t.Run("invalid escape", func(t *testing.T) {
+		tks, err := Tokenize(strings.NewReader(`"\q"`))
+		assert.Empty(t, <-tks)
+		assert.EqualError(t, <-err, "Tokenization failed: invalid escape sequence `\\q` in string")
+	})
+}
+
+func TestPositions(t *testing.T) {
+	assertTokenizeTo(t, "positions", "a =\n  \"b\"; -1.5", Token{
+		Pos:  CodePosition{CodePoint{Col: 1, Row: 1, Idx: 0}, CodePoint{Col: 1, Row: 1, Idx: 0}},
+		Type: TkName,
+		Text: "a",
+	}, Token{
+		Pos:  CodePosition{CodePoint{Col: 3, Row: 1, Idx: 2}, CodePoint{Col: 3, Row: 1, Idx: 2}},
+		Type: TkEqual,
+		Text: "=",
+	}, Token{
+		Pos:  CodePosition{CodePoint{Col: 3, Row: 2, Idx: 6}, CodePoint{Col: 5, Row: 2, Idx: 8}},
+		Type: TkStr,
+		Text: "b",
+	}, Token{
+		Pos:  CodePosition{CodePoint{Col: 6, Row: 2, Idx: 9}, CodePoint{Col: 6, Row: 2, Idx: 9}},
+		Type: TkSemicolon,
+		Text: ";",
+	}, Token{
+		Pos:    CodePosition{CodePoint{Col: 8, Row: 2, Idx: 11}, CodePoint{Col: 11, Row: 2, Idx: 14}},
+		Type:   TkDec,
+		Text:   "-1.5",
+		NumVal: -1.5,
+	})
+}
+
+func TestLexer(t *testing.T) {
+	l := NewLexer(strings.NewReader("a = 1.5 ;"))
+	var kinds []TokenType
+	for {
+		tk, err := l.Next()
+		require.NoError(t, err)
+		if tk.Type == TkEmpty {
+			break
+		}
+		kinds = append(kinds, tk.Type)
+	}
+	assert.Equal(t, []TokenType{TkName, TkEqual, TkDec, TkSemicolon}, kinds)
+
+	// the end is returned again
+	tk, err := l.Next()
+	require.NoError(t, err)
+	assert.Equal(t, TkEmpty, tk.Type)
+
+	l = NewLexer(strings.NewReader("a 1..2 b"))
+	tk, err = l.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "a", tk.Text)
+	_, err = l.Next()
+	assert.EqualError(t, err, "Tokenization failed: unexpected dot")
+	var d *Diagnostic
+	require.ErrorAs(t, err, &d)
+	assert.Equal(t, CodePoint{Col: 5, Row: 1, Idx: 4}, d.Pos)
+	// errors are returned again
+	_, err2 := l.Next()
+	assert.Equal(t, err, err2)
+}
+
+func TestParserLexer(t *testing.T) {
+	p := Parser{Lexer: NewLexer(strings.NewReader("netcdf fname {dimensions: a = 1;}"))}
+	f, err := p.Parse()
+	require.NoError(t, err)
+	assert.Equal(t, []types.Dimension{{Name: "a", Len: 1}}, f.Dimensions)
+}
+
+// benchmarkSource returns a CDL source with a large data section.
+func benchmarkSource() string {
+	var src strings.Builder
+	src.WriteString("netcdf bench {\ndimensions:\n\tx = 100000 ;\nvariables:\n\tdouble v(x) ;\ndata:\n v = ")
+	for i := 0; i < 100000; i++ {
+		if i > 0 {
+			src.WriteString(", ")
+		}
+		fmt.Fprintf(&src, "%d.%d", i, i%7)
+	}
+	src.WriteString(" ;\n}\n")
+	return src.String()
+}
+
+func BenchmarkLexer(b *testing.B) {
+	src := benchmarkSource()
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := NewLexer(strings.NewReader(src))
+		for {
+			tk, err := l.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if tk.Type == TkEmpty {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	src := benchmarkSource()
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tks, errs := Tokenize(strings.NewReader(src))
+		for range tks {
+		}
+		if err := <-errs; err != nil {
+			b.Fatal(err)
+		}
+	}
 }
@@ -0,0 +1,403 @@
+package cdl
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/parro-it/ncdf/read"
+	"github.com/parro-it/ncdf/types"
+)
+
+// AttrOrder is the order in which
+// attributes are written by FormatFile.
+type AttrOrder int
+
+const (
+	// DefinitionOrder writes attributes in
+	// the order they are defined in the file
+	DefinitionOrder AttrOrder = iota
+	// NameOrder writes attributes sorted by name
+	NameOrder
+)
+
+// FormatOptions configures the output of FormatFile.
+// Zero values select the defaults.
+type FormatOptions struct {
+	// Name is the name of the dataset, "dataset" by default
+	Name string
+	// Indent is the indentation of each level, a tab by default
+	Indent string
+	// ValuesPerLine is the number of numeric values
+	// written on each line of data, 10 by default
+	ValuesPerLine int
+	// FloatPrecision is the number of significant digits of
+	// floating point values. By default, they are written with
+	// the fewest digits that represent them exactly.
+	FloatPrecision int
+	// AttrOrder is the order of attributes
+	AttrOrder AttrOrder
+	// HeaderOnly omits the data section
+	HeaderOnly bool
+	// Format writes the format of the file in
+	// the _Format special attribute, that is
+	// read back by Parser
+	Format bool
+}
+
+// chunkLen is the number of values read
+// from disk at once by FormatFile.
+const chunkLen = 1 << 16
+
+// FormatFile writes f in CDL to w, with values of its variables
+// read from fd, unless fd is nil or opts.HeaderOnly is set.
+// Values are read and written a chunk at a time, so that
+// the memory used doesn't depend on the size of variables.
+// The output can be read back by Parser.
+func FormatFile(w io.Writer, f *types.File, fd io.ReadSeeker, opts FormatOptions) error {
+	if opts.Name == "" {
+		opts.Name = "dataset"
+	}
+	if opts.Indent == "" {
+		opts.Indent = "\t"
+	}
+	if opts.ValuesPerLine <= 0 {
+		opts.ValuesPerLine = 10
+	}
+
+	fm := &formatter{
+		w:    bufio.NewWriter(w),
+		opts: opts,
+		precision: func(types.Type) int {
+			return opts.FloatPrecision
+		},
+	}
+	fm.header(f)
+
+	if fd != nil && !opts.HeaderOnly {
+		var vars []types.Var
+		for _, v := range f.Vars.Values() {
+			if f.WholeSlab(v).Len() > 0 {
+				vars = append(vars, v)
+			}
+		}
+		if len(vars) > 0 {
+			fm.put("data:\n")
+		}
+		for _, v := range vars {
+			if err := fm.data(f, v, fd); err != nil {
+				return err
+			}
+		}
+	}
+
+	fm.put("}\n")
+	return fm.w.Flush()
+}
+
+type formatter struct {
+	w    *bufio.Writer
+	opts FormatOptions
+	// precision returns the number of significant
+	// digits of floating point values of a type
+	precision func(t types.Type) int
+}
+
+func (fm *formatter) put(s ...string) {
+	for _, x := range s {
+		fm.w.WriteString(x)
+	}
+}
+
+func (fm *formatter) header(f *types.File) {
+	in := fm.opts.Indent
+	fm.put("netcdf ", EscapeName(fm.opts.Name), " {\n")
+	if len(f.Dimensions) > 0 {
+		fm.put("dimensions:\n")
+		for _, d := range f.Dimensions {
+			if d.IsUnlimited() {
+				fm.put(in, EscapeName(d.Name), " = UNLIMITED ; // (", strconv.FormatInt(f.NumRecs, 10), " currently)\n")
+			} else {
+				fm.put(in, EscapeName(d.Name), " = ", strconv.FormatInt(d.Len, 10), " ;\n")
+			}
+		}
+	}
+
+	// global attributes belong to the variables section
+	if f.Vars.Len() > 0 || f.Attrs.Len() > 0 || fm.opts.Format {
+		fm.put("variables:\n")
+	}
+	if f.Vars.Len() > 0 {
+		for _, v := range f.Vars.Values() {
+			fm.put(in, v.Type.CDLName(), " ", EscapeName(v.Name))
+			if len(v.Dimensions) > 0 {
+				names := make([]string, len(v.Dimensions))
+				for i, d := range v.Dimensions {
+					names[i] = EscapeName(d.Name)
+				}
+				fm.put("(", strings.Join(names, ", "), ")")
+			}
+			fm.put(" ;\n")
+			fm.attrs(v.Attrs.Values(), v.Name)
+		}
+	}
+
+	if f.Attrs.Len() > 0 || fm.opts.Format {
+		fm.put("\n// global attributes:\n")
+		fm.attrs(f.Attrs.Values(), "")
+		if fm.opts.Format {
+			fm.put(in, in, ":_Format = ", quote(FormatName(f.Version)), " ;\n")
+		}
+	}
+}
+
+func (fm *formatter) attrs(attrs []types.Attr, varName string) {
+	if fm.opts.AttrOrder == NameOrder {
+		attrs = append([]types.Attr{}, attrs...)
+		sort.SliceStable(attrs, func(i, j int) bool {
+			return attrs[i].Name < attrs[j].Name
+		})
+	}
+	for _, a := range attrs {
+		fm.put(fm.opts.Indent, fm.opts.Indent, attrDecl(varName, a, formatAttr(a.Val, fm.precision(a.Type()))), "\n")
+	}
+}
+
+// data writes the values of variable v, read from fd.
+func (fm *formatter) data(f *types.File, v types.Var, fd io.ReadSeeker) error {
+	in := fm.opts.Indent
+	fill, err := v.FillValue()
+	if err != nil {
+		return err
+	}
+	fillStr := ""
+	if v.Type != types.Char {
+		fillStr = formatValues(fill, fm.precision(v.Type))[0]
+	}
+
+	perLine := int64(fm.opts.ValuesPerLine)
+	if v.Type == types.Char {
+		perLine = 1
+	}
+	fm.put(in, EscapeName(v.Name), " =")
+	if f.WholeSlab(v).Len() <= perLine {
+		fm.put(" ")
+	} else {
+		fm.put("\n", in, in)
+	}
+
+	var written int64
+	err = eachChunk(f, v, fd, func(values types.Value) error {
+		var strs []string
+		if v.Type == types.Char {
+			strs = formatText(v, values.Interface().([]byte))
+		} else {
+			strs = formatValues(values, fm.precision(v.Type))
+		}
+		for _, str := range strs {
+			if written > 0 {
+				if written%perLine == 0 {
+					fm.put(",\n", in, in)
+				} else {
+					fm.put(", ")
+				}
+			}
+			if v.Type != types.Char && str == fillStr {
+				str = "_"
+			}
+			fm.put(str)
+			written++
+		}
+		return fm.w.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	fm.put(" ;\n")
+	return nil
+}
+
+// eachChunk reads the values of variable v from fd, a chunk
+// of indexes of its first dimension at a time, and calls fn
+// with the values of each chunk.
+func eachChunk(f *types.File, v types.Var, fd io.ReadSeeker, fn func(values types.Value) error) error {
+	for _, s := range chunkSlabs(f, v) {
+		values, err := readSlab(f, v, s, fd)
+		if err != nil {
+			return err
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkSlabs splits the values of variable v in slabs
+// of about chunkLen values, along its first dimension.
+func chunkSlabs(f *types.File, v types.Var) []types.Slab {
+	whole := f.WholeSlab(v)
+
+	// rowLen is the number of values of
+	// each index of the first dimension
+	rowLen := int64(1)
+	for _, c := range whole.Count[min(1, len(whole.Count)):] {
+		rowLen *= c
+	}
+	rows := int64(1)
+	if len(whole.Count) > 0 {
+		rows = whole.Count[0]
+	}
+	chunkRows := max(1, chunkLen/max(1, rowLen))
+	// strings of char variables are split at the last
+	// dimension, that must not be split in chunks
+	if v.Type == types.Char && len(v.Dimensions) == 1 && !v.IsRecord() {
+		chunkRows = rows
+	}
+
+	var res []types.Slab
+	for start := int64(0); start < rows; start += chunkRows {
+		s := f.WholeSlab(v)
+		if len(s.Count) > 0 {
+			s.Start[0] = start
+			s.Count[0] = min(chunkRows, rows-start)
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// readSlab reads the values of slab s of variable v from fd.
+func readSlab(f *types.File, v types.Var, s types.Slab, fd io.ReadSeeker) (types.Value, error) {
+	data, err := read.SlabAny(f, v, s, fd)
+	if err != nil {
+		return types.Value{}, err
+	}
+	return types.NewValue(v.Type, data)
+}
+
+// formatText splits text, values of char variable v, in
+// quoted strings, one for each index of the last dimension.
+// NUL characters at the end of each string are omitted
+// when the last dimension is fixed, because the parser
+// pads strings to its length.
+func formatText(v types.Var, text []byte) []string {
+	rowLen := len(text)
+	trim := false
+	if n := len(v.Dimensions); n > 0 && !v.Dimensions[n-1].IsUnlimited() {
+		rowLen = int(v.Dimensions[n-1].Len)
+		trim = true
+	}
+	if rowLen == 0 {
+		return []string{quote("")}
+	}
+	var res []string
+	for i := 0; i < len(text); i += rowLen {
+		s := string(text[i:min(i+rowLen, len(text))])
+		if trim {
+			s = strings.TrimRight(s, "\x00")
+		}
+		res = append(res, quote(s))
+	}
+	return res
+}
+
+// formatValues returns the CDL literals of numeric values val.
+// Floating point values have precision significant digits,
+// or the fewest that represent them exactly when it's 0.
+func formatValues(val types.Value, precision int) []string {
+	switch val.Type() {
+	case types.Float, types.Double:
+		bits := 64
+		if val.Type() == types.Float {
+			bits = 32
+		}
+		numbers := val.Float64s()
+		res := make([]string, len(numbers))
+		for i, x := range numbers {
+			res[i] = formatNumber(x, bits, precision)
+		}
+		return res
+	}
+	return strings.Split(val.String(), ", ")
+}
+
+// formatNumber formats the floating point number x,
+// of the given size in bits, with precision significant
+// digits or the fewest that represent it exactly.
+func formatNumber(x float64, bits, precision int) string {
+	switch {
+	case math.IsNaN(x):
+		return "NaN"
+	case math.IsInf(x, 1):
+		return "Infinity"
+	case math.IsInf(x, -1):
+		return "-Infinity"
+	}
+	if x == 0 && math.Signbit(x) {
+		// "-0" would be read as the integer 0
+		return "-0."
+	}
+	if precision <= 0 {
+		precision = -1
+	}
+	return strconv.FormatFloat(x, 'g', precision, bits)
+}
+
+// typeSuffixes contains the suffixes of literals
+// of the types other than int and double.
+var typeSuffixes = map[types.Type]string{
+	types.Byte: "b", types.Short: "s", types.Float: "f",
+	types.UByte: "UB", types.UShort: "US", types.UInt: "U",
+	types.Int64: "L", types.UInt64: "UL",
+}
+
+// attrDecl returns the declaration of attribute a of variable
+// varName, whose values are formatted as value. Numeric
+// attributes with no values are declared with their type.
+func attrDecl(varName string, a types.Attr, value string) string {
+	decl := EscapeName(varName) + ":" + EscapeName(a.Name) + " ="
+	if a.Val.Len() == 0 && a.Type() != types.Char {
+		return a.Type().CDLName() + " " + decl + " ;"
+	}
+	return decl + " " + value + " ;"
+}
+
+// formatAttr returns the CDL literals of the values of an
+// attribute, with the suffixes that select their type.
+func formatAttr(val types.Value, precision int) string {
+	if val.Type() == types.Char {
+		return quote(val.String())
+	}
+	strs := formatValues(val, precision)
+	for i, s := range strs {
+		// floating point literals need a dot
+		// to not be read as integers
+		if (val.Type() == types.Float || val.Type() == types.Double) && !strings.ContainsAny(s, ".eEnN") {
+			s += "."
+		}
+		if val.Type() == types.Float && (s == "NaN" || strings.HasSuffix(s, "Infinity")) {
+			strs[i] = s + "f"
+			continue
+		}
+		strs[i] = s + typeSuffixes[val.Type()]
+	}
+	return strings.Join(strs, ", ")
+}
+
+func min[T int | int64](a, b T) T {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max[T int | int64](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,169 @@
+package cdl
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/parro-it/ncdf/read"
+	"github.com/parro-it/ncdf/types"
+	"github.com/parro-it/ncdf/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const formatHeader = `dimensions:
+	time = UNLIMITED ; // (2 currently)
+	x = 3 ;
+	len = 4 ;
+variables:
+	short x(x) ;
+		x:valid_range = 0s, 10s ;
+		x:scale = 0.5f ;
+	char names(x, len) ;
+	double scalar ;
+		scalar:offset = 0., 273.15 ;
+	double time(time) ;
+		time:units = "hours since 2000-01-01" ;
+	float temp(time, x) ;
+
+// global attributes:
+		:title = "a \"test\"\n" ;
+`
+
+func TestFormatFile(t *testing.T) {
+	f, fd := dumpFile(t)
+	format := func(fd *bytes.Reader, opts FormatOptions) string {
+		var out bytes.Buffer
+		require.NoError(t, FormatFile(&out, f, fd, opts))
+		return out.String()
+	}
+
+	assert.Equal(t, "netcdf dataset {\n"+formatHeader+`data:
+	x = 1, 2, 3 ;
+	names =
+		"ab",
+		"cd",
+		"efgh" ;
+	scalar = 1.5 ;
+	time = 0, 6.5 ;
+	temp = 0.1, 1e+10, _, -2, 3, _ ;
+}
+`, format(fd, FormatOptions{}))
+
+	assert.Equal(t, "netcdf test {\n"+formatHeader+"}\n", format(fd, FormatOptions{Name: "test", HeaderOnly: true}))
+	assert.Equal(t, "netcdf filename {\n"+formatHeader+"}\n", CDLFile(f))
+
+	assert.Equal(t, `netcdf dataset {
+dimensions:
+  time = UNLIMITED ; // (2 currently)
+  x = 3 ;
+  len = 4 ;
+variables:
+  short x(x) ;
+    x:scale = 0.5f ;
+    x:valid_range = 0s, 10s ;
+  char names(x, len) ;
+  double scalar ;
+    scalar:offset = 0., 3e+02 ;
+  double time(time) ;
+    time:units = "hours since 2000-01-01" ;
+  float temp(time, x) ;
+
+// global attributes:
+    :title = "a \"test\"\n" ;
+data:
+  x =
+    1, 2,
+    3 ;
+  names =
+    "ab",
+    "cd",
+    "efgh" ;
+  scalar = 2 ;
+  time = 0, 6 ;
+  temp =
+    0.1, 1e+10,
+    _, -2,
+    3, _ ;
+}
+`, format(fd, FormatOptions{Indent: "  ", ValuesPerLine: 2, FloatPrecision: 1, AttrOrder: NameOrder}))
+}
+
+func TestFormatFileParsesBack(t *testing.T) {
+	f, fd := dumpFile(t)
+	var out bytes.Buffer
+	require.NoError(t, FormatFile(&out, f, fd, FormatOptions{Name: "test"}))
+
+	g, err := ParseFile("test.cdl", &out)
+	require.NoError(t, err)
+	assert.Equal(t, f.NumRecs, g.NumRecs)
+	require.Equal(t, f.Vars.Len(), g.Vars.Len())
+	for _, v := range f.Vars.Values() {
+		data, err := read.VarDataAny(f, v, fd)
+		require.NoError(t, err)
+		expected, err := types.NewValue(v.Type, data)
+		require.NoError(t, err)
+		parsed := g.Vars.Get(v.Name)
+		assert.Equal(t, v.Attrs.Values(), parsed.Attrs.Values(), v.Name)
+		assert.Equal(t, expected, parsed.Data, v.Name)
+	}
+	assert.Equal(t, f.Attrs.Values(), g.Attrs.Values())
+}
+
+func TestFormatFileStreamsChunks(t *testing.T) {
+	dims := []types.Dimension{{Name: "x", Len: chunkLen*2 + 5}}
+	f := (&types.File{
+		Dimensions: dims,
+		Vars: types.Vars{
+			{Name: "v", Type: types.Double, Dimensions: []*types.Dimension{&dims[0]}},
+		}.Map(),
+	}).ComputeSizes()
+	values := make([]float64, dims[0].Len)
+	for i := range values {
+		values[i] = math.Sqrt(float64(i))
+	}
+	var buf buffer
+	require.NoError(t, write.Header(f, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("v"), values, &buf))
+
+	var out bytes.Buffer
+	require.NoError(t, FormatFile(&out, f, bytes.NewReader(buf.Bytes()), FormatOptions{ValuesPerLine: 7}))
+	// every line of data but the last has ValuesPerLine values
+	lines := strings.Split(out.String(), "\n")
+	for _, l := range lines[7 : len(lines)-3] {
+		require.Equal(t, 7, strings.Count(l, ","), l)
+	}
+
+	g, err := ParseFile("big.cdl", &out)
+	require.NoError(t, err)
+	assert.Equal(t, types.Doubles(values...), g.Vars.Get("v").Data)
+}
+
+func TestFormatNegativeZero(t *testing.T) {
+	f, fd := generate(t, "netcdf z {dimensions: x = 2; variables: double v(x); data: v = -0., 0;}")
+	var out bytes.Buffer
+	require.NoError(t, FormatFile(&out, f, fd, FormatOptions{}))
+	assert.Contains(t, out.String(), "v = -0., 0 ;")
+
+	g, err := ParseFile("z.cdl", &out)
+	require.NoError(t, err)
+	values := g.Vars.Get("v").Data.Float64s()
+	assert.True(t, math.Signbit(values[0]))
+	assert.False(t, math.Signbit(values[1]))
+}
+
+func TestFormatAttr(t *testing.T) {
+	assert.Equal(t, "1b, -1b", formatAttr(types.Bytes(1, 255), 0))
+	assert.Equal(t, "0.1f, 3.f, NaNf, -Infinityf", formatAttr(types.Floats(0.1, 3, float32(math.NaN()), float32(math.Inf(-1))), 0))
+	assert.Equal(t, "0.1, 1e+100, Infinity", formatAttr(types.Doubles(0.1, 1e100, math.Inf(1)), 0))
+	assert.Equal(t, "0.33", formatAttr(types.Doubles(1.0/3), 2))
+	assert.Equal(t, "-0.f", formatAttr(types.Floats(float32(math.Copysign(0, -1))), 0))
+	assert.Equal(t, "9223372036854775807L, 18446744073709551615UL",
+		formatAttr(types.Int64s(math.MaxInt64), 0)+", "+formatAttr(types.UInt64s(math.MaxUint64), 0))
+	assert.Equal(t, `"a\tb"`, formatAttr(types.Text("a\tb"), 0))
+
+	assert.Equal(t, "short v:e = ;", attrDecl("v", types.Attr{Name: "e", Val: types.Shorts()}, ""))
+	assert.Equal(t, `:e = "" ;`, attrDecl("", types.Attr{Name: "e", Val: types.Text("")}, `""`))
+}
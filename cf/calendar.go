@@ -0,0 +1,197 @@
+package cf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Calendar is a calendar defined by the CF conventions.
+type Calendar string
+
+// Calendars supported. Aliases are normalized by ParseCalendar.
+const (
+	// Standard is the mixed Gregorian/Julian calendar:
+	// dates before 1582-10-15 are in the Julian calendar.
+	Standard           Calendar = "standard"
+	ProlepticGregorian Calendar = "proleptic_gregorian"
+	Julian             Calendar = "julian"
+	NoLeap             Calendar = "noleap"
+	AllLeap            Calendar = "all_leap"
+	Days360            Calendar = "360_day"
+)
+
+// ParseCalendar returns the calendar named name, case insensitive.
+// An empty name returns the Standard calendar, and aliases
+// gregorian, 365_day and 366_day are normalized to
+// Standard, NoLeap and AllLeap.
+func ParseCalendar(name string) (Calendar, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "standard", "gregorian":
+		return Standard, nil
+	case "proleptic_gregorian":
+		return ProlepticGregorian, nil
+	case "julian":
+		return Julian, nil
+	case "noleap", "365_day":
+		return NoLeap, nil
+	case "all_leap", "366_day":
+		return AllLeap, nil
+	case "360_day":
+		return Days360, nil
+	}
+	return "", fmt.Errorf("Unknown calendar `%s`", name)
+}
+
+// IsReal returns whether dates of the calendar are days
+// of the real world, that can be represented as time.Time.
+func (c Calendar) IsReal() bool {
+	return c == Standard || c == ProlepticGregorian || c == Julian
+}
+
+// jdnUnixEpoch is the julian day number of 1970-01-01.
+const jdnUnixEpoch = 2440588
+
+// gregorianStart is the julian day number of 1582-10-15,
+// first day of the Gregorian calendar in the Standard one.
+const gregorianStart = 2299161
+
+// monthDays contains the cumulative days before
+// each month, for non leap and leap years.
+var monthDays = [2][13]int{
+	{0, 31, 59, 90, 120, 151, 181, 212, 243, 273, 304, 334, 365},
+	{0, 31, 60, 91, 121, 152, 182, 213, 244, 274, 305, 335, 366},
+}
+
+// dayNumber returns a count of days of the date y-m-d in
+// calendar c. For real calendars it's the julian day number,
+// so that it's the same for the same day in different calendars.
+func (c Calendar) dayNumber(y, m, d int) (int64, error) {
+	if m < 1 || m > 12 || d < 1 || d > c.monthLen(y, m) {
+		return 0, fmt.Errorf("Invalid date %04d-%02d-%02d in calendar %s", y, m, d, c)
+	}
+	switch c {
+	case ProlepticGregorian:
+		return gregorianJDN(y, m, d), nil
+	case Julian:
+		return julianJDN(y, m, d), nil
+	case Standard:
+		if n := gregorianJDN(y, m, d); n >= gregorianStart {
+			return n, nil
+		}
+		n := julianJDN(y, m, d)
+		if n >= gregorianStart {
+			return 0, fmt.Errorf("Invalid date %04d-%02d-%02d in calendar %s", y, m, d, c)
+		}
+		return n, nil
+	case NoLeap:
+		return int64(y)*365 + int64(monthDays[0][m-1]+d-1), nil
+	case AllLeap:
+		return int64(y)*366 + int64(monthDays[1][m-1]+d-1), nil
+	case Days360:
+		return int64(y)*360 + int64((m-1)*30+d-1), nil
+	}
+	return 0, fmt.Errorf("Unknown calendar `%s`", c)
+}
+
+// date returns the date of calendar c
+// corresponding to day number n.
+func (c Calendar) date(n int64) (y, m, d int) {
+	switch c {
+	case ProlepticGregorian:
+		return gregorianDate(n)
+	case Julian:
+		return julianDate(n)
+	case Standard:
+		if n >= gregorianStart {
+			return gregorianDate(n)
+		}
+		return julianDate(n)
+	case NoLeap:
+		return fixedYearDate(n, 365, monthDays[0])
+	case AllLeap:
+		return fixedYearDate(n, 366, monthDays[1])
+	case Days360:
+		y := floorDiv(n, 360)
+		rest := int(n - y*360)
+		return int(y), rest/30 + 1, rest%30 + 1
+	}
+	return 0, 0, 0
+}
+
+// monthLen returns the number of days of month m of year y.
+func (c Calendar) monthLen(y, m int) int {
+	leap := 0
+	switch c {
+	case Days360:
+		return 30
+	case AllLeap:
+		leap = 1
+	case ProlepticGregorian:
+		if isGregorianLeap(y) {
+			leap = 1
+		}
+	case Julian:
+		if y%4 == 0 {
+			leap = 1
+		}
+	case Standard:
+		if (y > 1582 && isGregorianLeap(y)) || (y <= 1582 && y%4 == 0) {
+			leap = 1
+		}
+	}
+	return monthDays[leap][m] - monthDays[leap][m-1]
+}
+
+func isGregorianLeap(y int) bool {
+	return y%4 == 0 && (y%100 != 0 || y%400 == 0)
+}
+
+func fixedYearDate(n int64, yearLen int64, days [13]int) (y, m, d int) {
+	year := floorDiv(n, yearLen)
+	rest := int(n - year*yearLen)
+	m = 1
+	for rest >= days[m] {
+		m++
+	}
+	return int(year), m, rest - days[m-1] + 1
+}
+
+// gregorianJDN returns the julian day number
+// of a date of the proleptic Gregorian calendar.
+func gregorianJDN(y, m, d int) int64 {
+	return jdnUnixEpoch + time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC).Unix()/86400
+}
+
+func gregorianDate(n int64) (y, m, d int) {
+	t := time.Unix((n-jdnUnixEpoch)*86400, 0).UTC()
+	return t.Year(), int(t.Month()), t.Day()
+}
+
+// julianJDN returns the julian day number
+// of a date of the Julian calendar.
+func julianJDN(y, m, d int) int64 {
+	a := int64((14 - m) / 12)
+	yy := int64(y) + 4800 - a
+	mm := int64(m) + 12*a - 3
+	return int64(d) + (153*mm+2)/5 + 365*yy + floorDiv(yy, 4) - 32083
+}
+
+func julianDate(n int64) (y, m, d int) {
+	c := n + 32082
+	dd := floorDiv(4*c+3, 1461)
+	e := c - floorDiv(1461*dd, 4)
+	mm := (5*e + 2) / 153
+	d = int(e - (153*mm+2)/5 + 1)
+	m = int(mm + 3 - 12*(mm/10))
+	y = int(dd - 4800 + mm/10)
+	return y, m, d
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
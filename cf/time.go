@@ -0,0 +1,282 @@
+// Package cf implements parts of the CF metadata
+// conventions on top of the netcdf data model.
+package cf
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parro-it/ncdf/types"
+)
+
+// Date is a date and time of a calendar. Dates of calendars
+// that are not real (see Calendar.IsReal) can't be represented
+// as time.Time, e.g. 2000-02-30 of the 360_day calendar.
+// Dates are always in UTC.
+type Date struct {
+	Year, Month, Day     int
+	Hour, Minute, Second int
+	Nanosecond           int
+	Calendar             Calendar
+}
+
+// NewDate returns the date of calendar c, returning
+// an error if it does not exist in the calendar.
+func NewDate(c Calendar, year, month, day, hour, min, sec, nsec int) (Date, error) {
+	d := Date{year, month, day, hour, min, sec, nsec, c}
+	if _, err := c.dayNumber(year, month, day); err != nil {
+		return Date{}, err
+	}
+	if hour < 0 || hour > 23 || min < 0 || min > 59 || sec < 0 || sec > 59 || nsec < 0 || nsec > 999999999 {
+		return Date{}, fmt.Errorf("Invalid time %02d:%02d:%02d", hour, min, sec)
+	}
+	return d, nil
+}
+
+// DateOf returns the date of calendar c corresponding to t.
+// It returns an error if c is not a real calendar.
+func DateOf(t time.Time, c Calendar) (Date, error) {
+	if !c.IsReal() {
+		return Date{}, fmt.Errorf("Cannot represent time %s in calendar %s", t, c)
+	}
+	t = t.UTC()
+	secs := t.Unix()
+	days := floorDiv(secs, 86400)
+	d := dateOfDay(c, days+jdnUnixEpoch, secs-days*86400)
+	d.Nanosecond = t.Nanosecond()
+	return d, nil
+}
+
+// dateOfDay returns the date of day number n
+// of calendar c, at sod seconds of the day.
+func dateOfDay(c Calendar, n int64, sod int64) Date {
+	y, m, d := c.date(n)
+	return Date{
+		Year: y, Month: m, Day: d,
+		Hour: int(sod / 3600), Minute: int(sod % 3600 / 60), Second: int(sod % 60),
+		Calendar: c,
+	}
+}
+
+// Time returns d as a time.Time in UTC.
+// It returns an error if the calendar of
+// d is not a real calendar.
+func (d Date) Time() (time.Time, error) {
+	if !d.Calendar.IsReal() {
+		return time.Time{}, fmt.Errorf("Cannot represent date %s of calendar %s as time.Time", d, d.Calendar)
+	}
+	n, err := d.Calendar.dayNumber(d.Year, d.Month, d.Day)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix((n-jdnUnixEpoch)*86400+d.secondOfDay(), int64(d.Nanosecond)).UTC(), nil
+}
+
+// String formats d as yyyy-mm-dd hh:mm:ss, with
+// fractional seconds if present.
+func (d Date) String() string {
+	s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d.Year, d.Month, d.Day, d.Hour, d.Minute, d.Second)
+	if d.Nanosecond != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%09d", d.Nanosecond), "0")
+	}
+	return s
+}
+
+func (d Date) secondOfDay() int64 {
+	return int64(d.Hour*3600 + d.Minute*60 + d.Second)
+}
+
+// Units are the units of a CF time coordinate,
+// as in "days since 1950-01-01".
+type Units struct {
+	// Unit is the duration of a unit of the values
+	Unit time.Duration
+	// Epoch is the reference date, in UTC
+	Epoch    Date
+	Calendar Calendar
+}
+
+var unitNames = map[string]time.Duration{
+	"days": 24 * time.Hour, "day": 24 * time.Hour, "d": 24 * time.Hour,
+	"hours": time.Hour, "hour": time.Hour, "hr": time.Hour, "h": time.Hour,
+	"minutes": time.Minute, "minute": time.Minute, "min": time.Minute,
+	"seconds": time.Second, "second": time.Second, "sec": time.Second, "s": time.Second,
+	"milliseconds": time.Millisecond, "millisecond": time.Millisecond, "msec": time.Millisecond, "ms": time.Millisecond,
+	"microseconds": time.Microsecond, "microsecond": time.Microsecond, "usec": time.Microsecond, "us": time.Microsecond,
+}
+
+var epochRe = regexp.MustCompile(
+	`^(-?\d+)-(\d{1,2})-(\d{1,2})` + // date
+		`(?:[T ](\d{1,2}):(\d{1,2})(?::(\d{1,2})(\.\d+)?)?)?` + // time
+		`\s*(?:Z|UTC|GMT|([+-]\d{1,2})(?::?(\d{2}))?)?$`, // time zone
+)
+
+// ParseUnits parses CF time units in the form
+// "<unit> since <date> [<time>] [<time zone>]",
+// with dates of the calendar named calendar (see ParseCalendar).
+func ParseUnits(units, calendar string) (Units, error) {
+	c, err := ParseCalendar(calendar)
+	if err != nil {
+		return Units{}, err
+	}
+
+	parts := strings.Fields(units)
+	if len(parts) < 3 || strings.ToLower(parts[1]) != "since" {
+		return Units{}, fmt.Errorf("Invalid time units `%s`", units)
+	}
+	unit, ok := unitNames[strings.ToLower(parts[0])]
+	if !ok {
+		return Units{}, fmt.Errorf("Unsupported time unit `%s`", parts[0])
+	}
+
+	// the reference date and time can be
+	// separated by any amount of spaces
+	ref := strings.Join(parts[2:], " ")
+	m := epochRe.FindStringSubmatch(ref)
+	if m == nil {
+		return Units{}, fmt.Errorf("Invalid reference date `%s`", ref)
+	}
+	num := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	var nsec int
+	if m[7] != "" {
+		frac, _ := strconv.ParseFloat(m[7], 64)
+		nsec = int(math.Round(frac * 1e9))
+	}
+	epoch, err := NewDate(c, num(m[1]), num(m[2]), num(m[3]), num(m[4]), num(m[5]), num(m[6]), nsec)
+	if err != nil {
+		return Units{}, err
+	}
+
+	u := Units{Unit: unit, Epoch: epoch, Calendar: c}
+	if m[8] != "" {
+		// the reference date is converted to UTC
+		offset := num(m[8]) * 3600
+		if offset < 0 || m[8][0] == '-' {
+			offset -= num(m[9]) * 60
+		} else {
+			offset += num(m[9]) * 60
+		}
+		u.Epoch, err = u.shift(epoch, -float64(offset))
+		if err != nil {
+			return Units{}, err
+		}
+	}
+	return u, nil
+}
+
+// UnitsOf returns the time units of variable v, read
+// from its units and calendar attributes.
+func UnitsOf(v types.Var) (Units, error) {
+	units, ok := v.Attr("units")
+	if !ok || units.Type() != types.Char {
+		return Units{}, fmt.Errorf("Variable `%s` has no units", v.Name)
+	}
+	var calendar string
+	if c, ok := v.Attr("calendar"); ok {
+		calendar = c.String()
+	}
+	return ParseUnits(units.String(), calendar)
+}
+
+// IsTime returns whether variable v is a time
+// coordinate, with valid CF time units.
+func IsTime(v types.Var) bool {
+	_, err := UnitsOf(v)
+	return err == nil
+}
+
+// String formats u as CF time units.
+func (u Units) String() string {
+	name := "seconds"
+	for _, n := range []string{"days", "hours", "minutes", "seconds", "milliseconds", "microseconds"} {
+		if unitNames[n] == u.Unit {
+			name = n
+			break
+		}
+	}
+	return name + " since " + u.Epoch.String()
+}
+
+// shift returns date d moved by secs seconds.
+func (u Units) shift(d Date, secs float64) (Date, error) {
+	n, err := u.Calendar.dayNumber(d.Year, d.Month, d.Day)
+	if err != nil {
+		return Date{}, err
+	}
+	whole := math.Floor(secs)
+	nsec := int64(math.Round((secs-whole)*1e9)) + int64(d.Nanosecond)
+	total := int64(whole) + d.secondOfDay() + floorDiv(nsec, 1e9)
+	nsec -= floorDiv(nsec, 1e9) * 1e9
+
+	days := floorDiv(total, 86400)
+	res := dateOfDay(u.Calendar, n+days, total-days*86400)
+	res.Nanosecond = int(nsec)
+	return res, nil
+}
+
+// Date returns the date corresponding to value.
+func (u Units) Date(value float64) (Date, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Date{}, fmt.Errorf("Invalid time value %g", value)
+	}
+	return u.shift(u.Epoch, value*u.Unit.Seconds())
+}
+
+// Dates returns the dates corresponding to values.
+func (u Units) Dates(values []float64) ([]Date, error) {
+	res := make([]Date, len(values))
+	for i, v := range values {
+		d, err := u.Date(v)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = d
+	}
+	return res, nil
+}
+
+// Value returns the value corresponding to date d,
+// that must be of the calendar of u.
+func (u Units) Value(d Date) (float64, error) {
+	if d.Calendar != u.Calendar {
+		return 0, fmt.Errorf("Cannot use date of calendar %s with units of calendar %s", d.Calendar, u.Calendar)
+	}
+	n, err := u.Calendar.dayNumber(d.Year, d.Month, d.Day)
+	if err != nil {
+		return 0, err
+	}
+	epoch, err := u.Calendar.dayNumber(u.Epoch.Year, u.Epoch.Month, u.Epoch.Day)
+	if err != nil {
+		return 0, err
+	}
+	secs := float64((n-epoch)*86400+d.secondOfDay()-u.Epoch.secondOfDay()) +
+		float64(d.Nanosecond-u.Epoch.Nanosecond)/1e9
+	return secs / u.Unit.Seconds(), nil
+}
+
+// Time returns the time corresponding to value.
+// It returns an error if the calendar of u is not real.
+func (u Units) Time(value float64) (time.Time, error) {
+	d, err := u.Date(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return d.Time()
+}
+
+// FromTime returns the value corresponding to t.
+// It returns an error if the calendar of u is not real.
+func (u Units) FromTime(t time.Time) (float64, error) {
+	d, err := DateOf(t, u.Calendar)
+	if err != nil {
+		return 0, err
+	}
+	return u.Value(d)
+}
@@ -0,0 +1,127 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/parro-it/ncdf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCalendar(t *testing.T) {
+	for name, c := range map[string]Calendar{
+		"":                    Standard,
+		"Gregorian":           Standard,
+		"proleptic_gregorian": ProlepticGregorian,
+		"365_day":             NoLeap,
+		"noleap":              NoLeap,
+		"366_day":             AllLeap,
+		"360_day":             Days360,
+		"julian":              Julian,
+	} {
+		got, err := ParseCalendar(name)
+		require.NoError(t, err)
+		assert.Equal(t, c, got, name)
+	}
+	_, err := ParseCalendar("lunar")
+	assert.EqualError(t, err, "Unknown calendar `lunar`")
+}
+
+func TestParseUnits(t *testing.T) {
+	u, err := ParseUnits("days since 1950-01-01", "")
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, u.Unit)
+	assert.Equal(t, Date{Year: 1950, Month: 1, Day: 1, Calendar: Standard}, u.Epoch)
+	assert.Equal(t, "days since 1950-01-01 00:00:00", u.String())
+
+	u, err = ParseUnits("hours since 2000-1-1T12:30:15.5Z", "noleap")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, u.Unit)
+	assert.Equal(t, "2000-01-01 12:30:15.5", u.Epoch.String())
+
+	u, err = ParseUnits("seconds since 2000-01-01 00:00:00 +01:00", "")
+	require.NoError(t, err)
+	assert.Equal(t, "1999-12-31 23:00:00", u.Epoch.String())
+
+	u, err = ParseUnits(" days  since\t1950-01-01   06:00 ", "")
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, u.Unit)
+	assert.Equal(t, "1950-01-01 06:00:00", u.Epoch.String())
+
+	_, err = ParseUnits("meters", "")
+	assert.EqualError(t, err, "Invalid time units `meters`")
+	_, err = ParseUnits("months since 2000-01-01", "")
+	assert.EqualError(t, err, "Unsupported time unit `months`")
+	_, err = ParseUnits("days since yesterday", "")
+	assert.EqualError(t, err, "Invalid reference date `yesterday`")
+	_, err = ParseUnits("days since 2001-02-29", "")
+	assert.EqualError(t, err, "Invalid date 2001-02-29 in calendar standard")
+}
+
+func TestUnitsDate(t *testing.T) {
+	date := func(units, calendar string, value float64) string {
+		u, err := ParseUnits(units, calendar)
+		require.NoError(t, err)
+		d, err := u.Date(value)
+		require.NoError(t, err)
+		back, err := u.Value(d)
+		require.NoError(t, err)
+		assert.InDelta(t, value, back, 1e-6)
+		return d.String()
+	}
+
+	assert.Equal(t, "1950-03-01 12:00:00", date("days since 1950-01-01", "standard", 59.5))
+	assert.Equal(t, "1949-12-31 00:00:00", date("days since 1950-01-01", "standard", -1))
+	assert.Equal(t, "2000-03-01 00:00:00", date("days since 2000-02-28", "noleap", 1))
+	assert.Equal(t, "2001-02-29 00:00:00", date("days since 2001-02-28", "all_leap", 1))
+	assert.Equal(t, "2000-02-30 00:00:00", date("days since 2000-02-01", "360_day", 29))
+	assert.Equal(t, "2001-01-01 00:00:00", date("days since 2000-01-01", "360_day", 360))
+	assert.Equal(t, "1900-02-29 00:00:00", date("days since 1900-02-28", "julian", 1))
+	assert.Equal(t, "1900-03-01 00:00:00", date("days since 1900-02-28", "proleptic_gregorian", 1))
+	assert.Equal(t, "2000-01-01 00:01:30", date("minutes since 2000-01-01", "", 1.5))
+	// the Gregorian calendar starts the day after 1582-10-04
+	assert.Equal(t, "1582-10-15 00:00:00", date("days since 1582-10-04", "standard", 1))
+	assert.Equal(t, "1582-10-05 00:00:00", date("days since 1582-10-04", "proleptic_gregorian", 1))
+}
+
+func TestUnitsTime(t *testing.T) {
+	u, err := ParseUnits("hours since 1970-01-01", "gregorian")
+	require.NoError(t, err)
+	tm, err := u.Time(25)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(1970, 1, 2, 1, 0, 0, 0, time.UTC), tm)
+
+	value, err := u.FromTime(time.Date(1970, 1, 3, 0, 30, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 48.5, value)
+
+	u, err = ParseUnits("days since 2000-01-01", "360_day")
+	require.NoError(t, err)
+	_, err = u.Time(59)
+	assert.EqualError(t, err, "Cannot represent date 2000-02-30 00:00:00 of calendar 360_day as time.Time")
+
+	u, err = ParseUnits("days since 1000-01-01", "julian")
+	require.NoError(t, err)
+	tm, err = u.Time(0)
+	require.NoError(t, err)
+	// julian dates are 5 days behind in early year 1000
+	assert.Equal(t, time.Date(1000, 1, 6, 0, 0, 0, 0, time.UTC), tm)
+}
+
+func TestUnitsOf(t *testing.T) {
+	v := types.Var{Name: "time", Type: types.Double, Attrs: types.Attrs{
+		{Name: "units", Val: types.Text("days since 1950-01-01")},
+		{Name: "calendar", Val: types.Text("noleap")},
+	}.Map()}
+	u, err := UnitsOf(v)
+	require.NoError(t, err)
+	assert.Equal(t, NoLeap, u.Calendar)
+	assert.True(t, IsTime(v))
+
+	dates, err := u.Dates([]float64{0, 365})
+	require.NoError(t, err)
+	assert.Equal(t, "1951-01-01 00:00:00", dates[1].String())
+
+	assert.False(t, IsTime(types.Var{Name: "x"}))
+}
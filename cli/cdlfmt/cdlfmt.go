@@ -0,0 +1,112 @@
+// Command cdlfmt formats CDL files, as gofmt does for Go
+// sources: it normalizes indentation and spacing, and
+// aligns the values of consecutive attributes, keeping
+// comments.
+//
+// Usage:
+//
+//	cdlfmt [-w] [-d] [-l] [file ...]
+//
+// Without flags, formatted files are written to the
+// standard output. Without files, the standard input
+// is formatted.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/parro-it/ncdf/cdl"
+)
+
+var (
+	write = flag.Bool("w", false, "write the result to the file instead of the standard output")
+	diff  = flag.Bool("d", false, "print the diff of the changes instead of the formatted file")
+	list  = flag.Bool("l", false, "list the files whose formatting differs from cdlfmt's")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-w] [-d] [-l] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "cdlfmt: cannot use -w with the standard input")
+			os.Exit(2)
+		}
+		if err := process("<standard input>", os.Stdin, os.Stdout); err != nil {
+			report(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	status := 0
+	for _, path := range flag.Args() {
+		if err := processFile(path); err != nil {
+			report(err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func report(err error) {
+	var diags cdl.Diagnostics
+	if errors.As(err, &diags) {
+		fmt.Fprintln(os.Stderr, diags.String())
+	} else {
+		fmt.Fprintf(os.Stderr, "cdlfmt: %s\n", err)
+	}
+}
+
+func processFile(path string) error {
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return process(path, fd, os.Stdout)
+}
+
+// process formats the source read from r, named path,
+// and outputs the result to out as requested by the flags.
+func process(path string, r io.Reader, out io.Writer) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	res, err := cdl.Format(path, src)
+	if err != nil {
+		return err
+	}
+
+	changed := !bytes.Equal(src, res)
+	if *list && changed {
+		fmt.Fprintln(out, path)
+	}
+	if *write && changed {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if *diff && changed {
+		fmt.Fprintf(out, "diff -u %s.orig %s\n", path, path)
+		io.WriteString(out, unifiedDiff(path+".orig", path, string(src), string(res)))
+	}
+	if !*list && !*write && !*diff {
+		_, err = out.Write(res)
+	}
+	return err
+}
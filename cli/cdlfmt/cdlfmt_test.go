@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	unformatted = "netcdf x {\ndimensions:\n  x=2;\n}\n"
+	formatted   = "netcdf x {\ndimensions:\n\tx = 2 ;\n}\n"
+)
+
+// withFlags sets the flags -w, -d and -l
+// for the duration of the test.
+func withFlags(t *testing.T, w, d, l bool) {
+	oldW, oldD, oldL := *write, *diff, *list
+	*write, *diff, *list = w, d, l
+	t.Cleanup(func() {
+		*write, *diff, *list = oldW, oldD, oldL
+	})
+}
+
+func TestProcess(t *testing.T) {
+	var out bytes.Buffer
+	withFlags(t, false, false, false)
+	require.NoError(t, process("x.cdl", strings.NewReader(unformatted), &out))
+	assert.Equal(t, formatted, out.String())
+
+	out.Reset()
+	withFlags(t, false, false, true)
+	require.NoError(t, process("x.cdl", strings.NewReader(unformatted), &out))
+	assert.Equal(t, "x.cdl\n", out.String())
+
+	// formatted files are not listed
+	out.Reset()
+	require.NoError(t, process("x.cdl", strings.NewReader(formatted), &out))
+	assert.Equal(t, "", out.String())
+
+	out.Reset()
+	withFlags(t, false, true, false)
+	require.NoError(t, process("x.cdl", strings.NewReader(unformatted), &out))
+	assert.Equal(t, `diff -u x.cdl.orig x.cdl
+--- x.cdl.orig
++++ x.cdl
+@@ -1,4 +1,4 @@
+ netcdf x {
+ dimensions:
+-  x=2;
++	x = 2 ;
+ }
+`, out.String())
+
+	out.Reset()
+	err := process("x.cdl", strings.NewReader("netcdf x {"), &out)
+	assert.EqualError(t, err, "Parse failed: `}` expected")
+	assert.Equal(t, "", out.String())
+}
+
+func TestProcessWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.cdl")
+	require.NoError(t, os.WriteFile(path, []byte(unformatted), 0640))
+	withFlags(t, true, false, false)
+
+	require.NoError(t, processFile(path))
+	res, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, formatted, string(res))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	assert.Error(t, processFile(filepath.Join(t.TempDir(), "missing.cdl")))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines
+// printed around changes in diffs.
+const context = 3
+
+// maxCells limits the size of the table used to
+// compare the changed lines: larger changes are
+// reported as the replacement of all the lines.
+const maxCells = 1 << 22
+
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns the changes from a, named nameA,
+// to b, named nameB, in the unified diff format.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	es := edits(splitLines(a), splitLines(b))
+
+	// aPos[k] and bPos[k] are the number of lines
+	// of a and b before edit k
+	aPos := make([]int, len(es)+1)
+	bPos := make([]int, len(es)+1)
+	for k, e := range es {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if e.op != '+' {
+			aPos[k+1]++
+		}
+		if e.op != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	var res strings.Builder
+	fmt.Fprintf(&res, "--- %s\n+++ %s\n", nameA, nameB)
+	for i := 0; i < len(es); {
+		if es[i].op == ' ' {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		last := i
+		for j := i; j < len(es) && j-last <= 2*context; j++ {
+			if es[j].op != ' ' {
+				last = j
+			}
+		}
+		end := last + context + 1
+		if end > len(es) {
+			end = len(es)
+		}
+
+		fmt.Fprintf(&res, "@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[end]), hunkRange(bPos[start], bPos[end]))
+		for _, e := range es[start:end] {
+			res.WriteByte(e.op)
+			res.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				res.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return res.String()
+}
+
+// hunkRange formats the range of lines from (excluded) to to.
+func hunkRange(from, to int) string {
+	if to-from == 0 {
+		return fmt.Sprintf("%d,0", from)
+	}
+	if to-from == 1 {
+		return fmt.Sprintf("%d", from+1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// edits returns the edits that change a in b, using
+// the longest common subsequence of their lines.
+func edits(a, b []string) []edit {
+	var es []edit
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		es = append(es, edit{' ', a[pre]})
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	am, bm := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	if len(am)*len(bm) > maxCells {
+		for _, l := range am {
+			es = append(es, edit{'-', l})
+		}
+		for _, l := range bm {
+			es = append(es, edit{'+', l})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest
+		// common subsequence of am[i:] and bm[j:]
+		lcs := make([][]int, len(am)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(bm)+1)
+		}
+		for i := len(am) - 1; i >= 0; i-- {
+			for j := len(bm) - 1; j >= 0; j-- {
+				if am[i] == bm[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(am) || j < len(bm) {
+			switch {
+			case i < len(am) && j < len(bm) && am[i] == bm[j]:
+				es = append(es, edit{' ', am[i]})
+				i++
+				j++
+			case j == len(bm) || i < len(am) && lcs[i+1][j] >= lcs[i][j+1]:
+				es = append(es, edit{'-', am[i]})
+				i++
+			default:
+				es = append(es, edit{'+', bm[j]})
+				j++
+			}
+		}
+	}
+
+	for _, l := range a[len(a)-suf:] {
+		es = append(es, edit{' ', l})
+	}
+	return es
+}
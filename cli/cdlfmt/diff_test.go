@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func numbered(from, to int) string {
+	var res strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&res, "%d\n", i)
+	}
+	return res.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := numbered(1, 10)
+	b := strings.Replace(a, "5\n", "five\n", 1)
+	assert.Equal(t, `--- a
++++ b
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`, unifiedDiff("a", "b", a, b))
+
+	// changes farther than twice the context are in different hunks
+	b = strings.Replace(strings.Replace(numbered(1, 20), "2\n", "two\n", 1), "19\n", "", 1)
+	assert.Equal(t, `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -16,5 +16,4 @@
+ 16
+ 17
+ 18
+-19
+ 20
+`, unifiedDiff("a", "b", numbered(1, 20), b))
+
+	// nearer changes share a hunk
+	b = strings.Replace(strings.Replace(a, "2\n", "two\n", 1), "8\n", "eight\n", 1)
+	assert.Equal(t, `--- a
++++ b
+@@ -1,10 +1,10 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+ 6
+ 7
+-8
++eight
+ 9
+ 10
+`, unifiedDiff("a", "b", a, b))
+}
+
+func TestUnifiedDiffEdges(t *testing.T) {
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n", unifiedDiff("a", "b", "", "x\ny\n"))
+	assert.Equal(t, "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n", unifiedDiff("a", "b", "x\n", ""))
+	assert.Equal(t, "--- a\n+++ b\n", unifiedDiff("a", "b", "x\n", "x\n"))
+
+	assert.Equal(t, `--- a
++++ b
+@@ -1,2 +1,2 @@
+ x
+-y
+\ No newline at end of file
++y
+`, unifiedDiff("a", "b", "x\ny", "x\ny\n"))
+}
+
+func TestUnifiedDiffLarge(t *testing.T) {
+	// changed lines too many to be compared are
+	// all removed, then all added
+	n := 3000
+	a := numbered(1, n)
+	b := numbered(n+1, 2*n)
+	diff := unifiedDiff("a", "b", "same\n"+a, "same\n"+b)
+	lines := strings.Split(diff, "\n")
+	assert.Equal(t, fmt.Sprintf("@@ -1,%d +1,%d @@", n+1, n+1), lines[2])
+	assert.Equal(t, " same", lines[3])
+	assert.Equal(t, "-1", lines[4])
+	assert.Equal(t, fmt.Sprintf("-%d", n), lines[n+3])
+	assert.Equal(t, fmt.Sprintf("+%d", n+1), lines[n+4])
+	assert.Equal(t, fmt.Sprintf("+%d", 2*n), lines[2*n+3])
+}
+
+func TestHunkRange(t *testing.T) {
+	assert.Equal(t, "3,0", hunkRange(3, 3))
+	assert.Equal(t, "4", hunkRange(3, 4))
+	assert.Equal(t, "4,3", hunkRange(3, 6))
+}
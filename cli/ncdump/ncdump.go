@@ -0,0 +1,65 @@
+// Command ncdump prints the content of netcdf files
+// in CDL, with the same layout of Unidata's ncdump.
+//
+// Usage:
+//
+//	ncdump [-h] [-c] [-v var1,...] [-s] [-t] file...
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/parro-it/ncdf/cdl"
+	"github.com/parro-it/ncdf/read"
+)
+
+func main() {
+	var opts cdl.DumpOptions
+	var vars string
+	flag.BoolVar(&opts.HeaderOnly, "h", false, "print only the header")
+	flag.BoolVar(&opts.Coords, "c", false, "print data of coordinate variables only")
+	flag.StringVar(&vars, "v", "", "print data of the given comma separated variables only")
+	flag.BoolVar(&opts.Special, "s", false, "print special virtual attributes")
+	flag.BoolVar(&opts.Times, "t", false, "print CF time values as ISO dates")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-h] [-c] [-v var1,...] [-s] [-t] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if vars != "" {
+		opts.Vars = strings.Split(vars, ",")
+	}
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, path := range flag.Args() {
+		if err := dump(path, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "ncdump: %s: %s\n", path, err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func dump(path string, opts cdl.DumpOptions) error {
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	f, err := read.Header(fd)
+	if err != nil {
+		return err
+	}
+
+	opts.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return cdl.Dump(os.Stdout, f, fd, opts)
+}
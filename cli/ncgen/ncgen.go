@@ -0,0 +1,113 @@
+// Command ncgen builds a netcdf file from its CDL description,
+// including values of the data section.
+//
+// Usage:
+//
+//	ncgen [-k kind] [-o file.nc] file.cdl
+//
+// kind is the format of the output file: `classic` (or 1),
+// `64-bit offset` (or 2) and `cdf5` (or 5). Without -k, the
+// format is read from the _Format global attribute of the
+// CDL file, and defaults to `64-bit offset`.
+// When -o is omitted, the output file is named after
+// the CDL file with the .nc extension.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/parro-it/ncdf/cdl"
+	"github.com/parro-it/ncdf/types"
+	"github.com/parro-it/ncdf/write"
+)
+
+func main() {
+	kind := flag.String("k", "", "format of the output file: classic, 64-bit offset or cdf5")
+	out := flag.String("o", "", "path of the output file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-k kind] [-o file.nc] file.cdl\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in := flag.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(in, filepath.Ext(in)) + ".nc"
+	}
+	if err := generate(in, *out, *kind); err != nil {
+		var diags cdl.Diagnostics
+		if errors.As(err, &diags) {
+			fmt.Fprintln(os.Stderr, diags.String())
+		} else {
+			fmt.Fprintf(os.Stderr, "ncgen: %s\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// parseKind returns the version of the file format
+// named kind, or the zero version if kind is empty.
+func parseKind(kind string) (types.Version, error) {
+	switch strings.ToLower(kind) {
+	case "":
+		return types.Version{}, nil
+	case "classic", "1", "nc3":
+		return types.CDF1, nil
+	case "64-bit offset", "64-bit-offset", "2", "nc6":
+		return types.CDF2, nil
+	case "cdf5", "64-bit data", "64-bit-data", "5", "nc5":
+		return types.CDF5, nil
+	}
+	return types.Version{}, fmt.Errorf("Unknown format kind `%s`", kind)
+}
+
+func generate(in, out, kind string) error {
+	ver, err := parseKind(kind)
+	if err != nil {
+		return err
+	}
+
+	r, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := cdl.ParseFile(in, r)
+	if err != nil {
+		return err
+	}
+	if ver != (types.Version{}) {
+		f.Version = ver
+	}
+	f.Version = f.Version.OrDefault()
+	f.ComputeSizes()
+
+	fd, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := write.Header(f, fd); err != nil {
+		fd.Close()
+		return err
+	}
+	// values missing in the data section are left
+	// with the fill value of their variable
+	if err := write.Fill(f, fd); err != nil {
+		fd.Close()
+		return err
+	}
+	if err := write.Data(f, fd); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
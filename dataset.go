@@ -0,0 +1,570 @@
+package ncdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/parro-it/ncdf/ndarray"
+	"github.com/parro-it/ncdf/read"
+	"github.com/parro-it/ncdf/types"
+	"github.com/parro-it/ncdf/write"
+)
+
+// ErrDefineMode is returned by operations that
+// are not allowed while the dataset is in define mode.
+var ErrDefineMode = errors.New("Dataset is in define mode")
+
+// ErrDataMode is returned by operations that
+// are allowed only while the dataset is in define mode.
+var ErrDataMode = errors.New("Dataset is not in define mode")
+
+// ErrReadOnly is returned by operations that
+// modify a dataset open read only.
+var ErrReadOnly = errors.New("Dataset is read only")
+
+// Dataset is a netcdf file open on disk.
+// It owns the file descriptor and the parsed header.
+//
+// A Dataset is either in define mode, where dimensions,
+// variables and attributes can be added or changed, or in
+// data mode, where values of variables can be read and written.
+// Leaving define mode writes the header and, if needed, moves
+// already written values to their new position.
+//
+// A Dataset is not safe for concurrent use.
+type Dataset struct {
+	fd       *os.File
+	header   *types.File
+	writable bool
+	define   bool
+	// stored is the layout of the variables currently
+	// written on disk, nil if no header was written yet.
+	stored *layout
+}
+
+// layout contains the position of data of
+// variables in the file.
+type layout struct {
+	vars    map[string]types.Var
+	recSize int64
+	numRecs int64
+}
+
+// Open opens the netcdf file at path read only.
+func Open(path string) (*Dataset, error) {
+	return OpenFile(path, os.O_RDONLY)
+}
+
+// OpenFile opens the netcdf file at path, using flag
+// to choose the access mode like os.OpenFile.
+// Use os.O_RDWR to open a dataset that can be modified.
+func OpenFile(path string, flag int) (*Dataset, error) {
+	fd, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := read.Header(fd)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	ds := &Dataset{
+		fd:       fd,
+		header:   f,
+		writable: flag&(os.O_RDWR|os.O_WRONLY) != 0,
+	}
+	ds.stored = ds.layout()
+	return ds, nil
+}
+
+// Create creates a new netcdf file at path, truncating it
+// if it already exists, and returns a Dataset in define mode.
+// f contains the initial header of the file; when nil, an empty
+// CDF-2 header is used.
+func Create(path string, f *types.File) (*Dataset, error) {
+	if f == nil {
+		f = &types.File{Version: types.CDF2}
+	}
+	f.Version = f.Version.OrDefault()
+	if err := f.Version.Check(); err != nil {
+		return nil, err
+	}
+
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dataset{
+		fd:       fd,
+		header:   f,
+		writable: true,
+		define:   true,
+	}, nil
+}
+
+// Header returns the header of the dataset.
+// It must not be modified directly: use the methods
+// of the dataset in define mode.
+func (ds *Dataset) Header() *types.File {
+	return ds.header
+}
+
+// Name returns the path of the file of the dataset.
+func (ds *Dataset) Name() string {
+	return ds.fd.Name()
+}
+
+// InDefineMode returns whether the dataset is in define mode.
+func (ds *Dataset) InDefineMode() bool {
+	return ds.define
+}
+
+// Redef puts the dataset in define mode.
+func (ds *Dataset) Redef() error {
+	if !ds.writable {
+		return ErrReadOnly
+	}
+	if ds.define {
+		return ErrDefineMode
+	}
+	// writes in data mode can add records:
+	// the layout stored on disk is taken again.
+	ds.stored = ds.layout()
+	ds.define = true
+	return nil
+}
+
+// EndDef leaves define mode, computes the layout of
+// variables and writes the header on disk.
+// Values of variables already written are moved
+// if their position changed, and new variables are
+// pre-filled with their fill value.
+func (ds *Dataset) EndDef() error {
+	if !ds.define {
+		return ErrDataMode
+	}
+
+	ds.header.ComputeSizes()
+	if ds.stored != nil {
+		if err := ds.relocate(ds.stored); err != nil {
+			return err
+		}
+	}
+
+	if err := ds.writeHeader(); err != nil {
+		return err
+	}
+
+	if err := ds.extend(); err != nil {
+		return err
+	}
+
+	if err := ds.fillNewVars(); err != nil {
+		return err
+	}
+
+	ds.stored = ds.layout()
+	ds.define = false
+	return nil
+}
+
+// Close closes the dataset, leaving define mode
+// first if needed.
+func (ds *Dataset) Close() error {
+	if ds.define {
+		if err := ds.EndDef(); err != nil {
+			ds.fd.Close()
+			return err
+		}
+	}
+	return ds.fd.Close()
+}
+
+// AddDim adds a dimension to the dataset.
+// Use length 0 for the unlimited dimension.
+func (ds *Dataset) AddDim(name string, length int64) error {
+	if !ds.define {
+		return ErrDataMode
+	}
+	if length < 0 {
+		return fmt.Errorf("Negative length for dimension `%s`", name)
+	}
+	for _, d := range ds.header.Dimensions {
+		if d.Name == name {
+			return fmt.Errorf("Dimension `%s` already exists", name)
+		}
+		if length == 0 && d.IsUnlimited() {
+			return fmt.Errorf("Unlimited dimension `%s` already exists", d.Name)
+		}
+	}
+
+	ds.header.Dimensions = append(ds.header.Dimensions, types.Dimension{
+		Name: name,
+		Len:  length,
+	})
+	ds.rebindDimensions()
+	return nil
+}
+
+// rebindDimensions makes dimensions of variables point
+// to items of ds.header.Dimensions, that is reallocated
+// by append.
+func (ds *Dataset) rebindDimensions() {
+	for _, it := range ds.header.Vars.Items() {
+		v := it.V
+		dims := make([]*types.Dimension, len(v.Dimensions))
+		for i, d := range v.Dimensions {
+			dims[i] = ds.dimension(d.Name)
+		}
+		v.Dimensions = dims
+		ds.header.Vars.Set(it.K, v)
+	}
+}
+
+func (ds *Dataset) dimension(name string) *types.Dimension {
+	for i := range ds.header.Dimensions {
+		if ds.header.Dimensions[i].Name == name {
+			return &ds.header.Dimensions[i]
+		}
+	}
+	return nil
+}
+
+// AddVar adds a variable of type t to the dataset.
+// dims contains the names of its dimensions.
+func (ds *Dataset) AddVar(name string, t types.Type, dims ...string) error {
+	if !ds.define {
+		return ErrDataMode
+	}
+	if ds.header.Vars.Has(name) {
+		return fmt.Errorf("Variable `%s` already exists", name)
+	}
+	if !ds.header.Version.Supports(t) {
+		return fmt.Errorf("Type %s of `%s` is not supported by version %d", t, name, ds.header.Version[3])
+	}
+
+	v := types.Var{Name: name, Type: t}
+	for i, dn := range dims {
+		d := ds.dimension(dn)
+		if d == nil {
+			return fmt.Errorf("Unknown dimension `%s`", dn)
+		}
+		if i > 0 && d.IsUnlimited() {
+			return fmt.Errorf("Unlimited dimension `%s` must be the first one of variable `%s`", dn, name)
+		}
+		v.Dimensions = append(v.Dimensions, d)
+	}
+	ds.header.Vars.Set(name, v)
+	return nil
+}
+
+// SetAttr adds or replaces a global attribute.
+func (ds *Dataset) SetAttr(a types.Attr) error {
+	if !ds.define {
+		return ErrDataMode
+	}
+	ds.header.Attrs.Set(a.Name, a)
+	return nil
+}
+
+// SetVarAttr adds or replaces an attribute of variable varName.
+func (ds *Dataset) SetVarAttr(varName string, a types.Attr) error {
+	if !ds.define {
+		return ErrDataMode
+	}
+	if !ds.header.Vars.Has(varName) {
+		return fmt.Errorf("Unknown variable `%s`", varName)
+	}
+	v := ds.header.Vars.Get(varName)
+	v.Attrs.Set(a.Name, a)
+	ds.header.Vars.Set(varName, v)
+	return nil
+}
+
+// Var returns an handle to the variable called name.
+// Values of the variable are read only when requested
+// through the handle.
+func (ds *Dataset) Var(name string) (*Variable, error) {
+	if !ds.header.Vars.Has(name) {
+		return nil, fmt.Errorf("Unknown variable `%s`", name)
+	}
+	return &Variable{ds: ds, name: name}, nil
+}
+
+// Variable is an handle to a variable of a Dataset.
+type Variable struct {
+	ds   *Dataset
+	name string
+}
+
+// Info returns the header of the variable.
+func (v *Variable) Info() types.Var {
+	return v.ds.header.Vars.Get(v.name)
+}
+
+// Read reads all values of the variable, returning
+// a slice whose element type depends on the type of
+// the variable, see read.VarDataAny.
+func (v *Variable) Read() (interface{}, error) {
+	if v.ds.define {
+		return nil, ErrDefineMode
+	}
+	return read.VarDataAny(v.ds.header, v.Info(), v.ds.fd)
+}
+
+// ReadSlab reads values of the variable selected by s,
+// returning a slice whose element type depends on the type of
+// the variable, see read.SlabAny.
+func (v *Variable) ReadSlab(s types.Slab) (interface{}, error) {
+	if v.ds.define {
+		return nil, ErrDefineMode
+	}
+	return read.SlabAny(v.ds.header, v.Info(), s, v.ds.fd)
+}
+
+// Values reads all values of variable v.
+func Values[T types.BaseType](v *Variable) ([]T, error) {
+	if v.ds.define {
+		return nil, ErrDefineMode
+	}
+	return read.VarData[T](v.ds.header, v.Info(), v.ds.fd)
+}
+
+// SlabValues reads values of variable v selected by s.
+func SlabValues[T types.BaseType](v *Variable, s types.Slab) ([]T, error) {
+	if v.ds.define {
+		return nil, ErrDefineMode
+	}
+	return read.Slab[T](v.ds.header, v.Info(), s, v.ds.fd)
+}
+
+// Array reads all values of variable v in an
+// array shaped after its dimensions.
+func Array[T types.BaseType](v *Variable) (*ndarray.Array[T], error) {
+	if v.ds.define {
+		return nil, ErrDefineMode
+	}
+	return read.VarArray[T](v.ds.header, v.Info(), v.ds.fd)
+}
+
+// SlabArray reads values of variable v selected by s
+// in an array of shape s.Count.
+func SlabArray[T types.BaseType](v *Variable, s types.Slab) (*ndarray.Array[T], error) {
+	if v.ds.define {
+		return nil, ErrDefineMode
+	}
+	return read.SlabArray[T](v.ds.header, v.Info(), s, v.ds.fd)
+}
+
+// Write writes all values of variable v. It returns
+// an error if data doesn't match the type or the
+// number of values of v.
+func Write[T types.BaseType](v *Variable, data []T) error {
+	if err := v.ds.checkDataMode(); err != nil {
+		return err
+	}
+	return write.VarData(v.ds.header, v.Info(), data, v.ds.fd)
+}
+
+// WriteSlab writes values of variable v selected by s.
+// It returns an error if data doesn't match the type of v.
+func WriteSlab[T types.BaseType](v *Variable, s types.Slab, data []T) error {
+	if err := v.ds.checkDataMode(); err != nil {
+		return err
+	}
+	return write.Slab(v.ds.header, v.Info(), s, data, v.ds.fd)
+}
+
+func (ds *Dataset) checkDataMode() error {
+	if !ds.writable {
+		return ErrReadOnly
+	}
+	if ds.define {
+		return ErrDefineMode
+	}
+	return nil
+}
+
+func (ds *Dataset) writeHeader() error {
+	var buf bytes.Buffer
+	if err := write.Header(ds.header, &buf); err != nil {
+		return err
+	}
+	if _, err := ds.fd.WriteAt(buf.Bytes(), 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// fillNewVars pre-fills the variables
+// not yet stored on disk.
+func (ds *Dataset) fillNewVars() error {
+	for _, v := range ds.header.Vars.Values() {
+		if ds.stored != nil {
+			if _, ok := ds.stored.vars[v.Name]; ok {
+				continue
+			}
+		}
+		if err := write.FillVar(ds.header, v, ds.fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extend grows the file so that it contains
+// the values of all variables.
+func (ds *Dataset) extend() error {
+	end := int64(ds.header.ByteSize())
+	for _, v := range ds.header.Vars.Values() {
+		vend := int64(v.Offset) + v.Size
+		if v.IsRecord() {
+			recs := ds.header.RecordVars()
+			vend = int64(recs[0].Offset) + ds.header.NumRecs*ds.header.RecSize()
+		}
+		if vend > end {
+			end = vend
+		}
+	}
+
+	st, err := ds.fd.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() < end {
+		return ds.fd.Truncate(end)
+	}
+	return nil
+}
+
+func (ds *Dataset) layout() *layout {
+	l := &layout{
+		vars:    map[string]types.Var{},
+		recSize: ds.header.RecSize(),
+		numRecs: ds.header.NumRecs,
+	}
+	for _, v := range ds.header.Vars.Values() {
+		l.vars[v.Name] = v
+	}
+	return l
+}
+
+// move is a range of bytes that must be
+// moved from position `from` to position `to`.
+type move struct {
+	from, to, len int64
+}
+
+// relocate moves values of variables from their position
+// in the old layout to the one in the current header.
+func (ds *Dataset) relocate(old *layout) error {
+	var moves []move
+	forward, backward := false, false
+	for _, v := range ds.header.Vars.Values() {
+		ov, ok := old.vars[v.Name]
+		if !ok {
+			continue
+		}
+		if !v.IsRecord() {
+			moves = append(moves, move{int64(ov.Offset), int64(v.Offset), ov.Size})
+			continue
+		}
+		recLen := v.RecordLen() * int64(v.Type.ScalarSize())
+		for r := int64(0); r < old.numRecs; r++ {
+			moves = append(moves, move{
+				from: int64(ov.Offset) + r*old.recSize,
+				to:   int64(v.Offset) + r*ds.header.RecSize(),
+				len:  recLen,
+			})
+		}
+	}
+
+	for _, m := range moves {
+		forward = forward || m.to > m.from
+		backward = backward || m.to < m.from
+	}
+
+	if forward && backward {
+		// moves in both directions could overwrite values
+		// not yet moved, so they are all read in memory first.
+		bufs := make([][]byte, len(moves))
+		for i, m := range moves {
+			bufs[i] = make([]byte, m.len)
+			if err := ds.readAt(bufs[i], m.from); err != nil {
+				return err
+			}
+		}
+		for i, m := range moves {
+			if _, err := ds.fd.WriteAt(bufs[i], m.to); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// values moving forward are moved starting from the last one,
+	// so that they never overwrite values not yet moved.
+	sort.Slice(moves, func(i, j int) bool {
+		if forward {
+			return moves[i].from > moves[j].from
+		}
+		return moves[i].from < moves[j].from
+	})
+	for _, m := range moves {
+		if m.from == m.to {
+			continue
+		}
+		if err := ds.copyRange(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyRange copies a range of bytes of the file, one
+// chunk at a time. Chunks are copied starting from the
+// end of the range when moving forward, since the
+// destination can overlap the source.
+func (ds *Dataset) copyRange(m move) error {
+	const chunkSize = 1 << 20
+	buf := make([]byte, chunkSize)
+	for done := int64(0); done < m.len; {
+		n := m.len - done
+		if n > chunkSize {
+			n = chunkSize
+		}
+		pos := done
+		if m.to > m.from {
+			pos = m.len - done - n
+		}
+		if err := ds.readAt(buf[:n], m.from+pos); err != nil {
+			return err
+		}
+		if _, err := ds.fd.WriteAt(buf[:n], m.to+pos); err != nil {
+			return err
+		}
+		done += n
+	}
+	return nil
+}
+
+// readAt reads len(buf) bytes at offset off. Bytes
+// beyond the end of file, that were never written,
+// are read as zeros.
+func (ds *Dataset) readAt(buf []byte, off int64) error {
+	n, err := ds.fd.ReadAt(buf, off)
+	if err == io.EOF {
+		for i := n; i < len(buf); i++ {
+			buf[i] = 0
+		}
+		return nil
+	}
+	return err
+}
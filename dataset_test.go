@@ -0,0 +1,185 @@
+package ncdf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parro-it/ncdf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestDataset(t *testing.T, path string) {
+	ds, err := Create(path, nil)
+	require.NoError(t, err)
+	assert.True(t, ds.InDefineMode())
+
+	require.NoError(t, ds.AddDim("time", 0))
+	require.NoError(t, ds.AddDim("x", 3))
+	require.NoError(t, ds.AddVar("x", types.Short, "x"))
+	require.NoError(t, ds.AddVar("temp", types.Float, "time", "x"))
+	require.NoError(t, ds.SetVarAttr("temp", types.Attr{Name: "len", Val: types.Shorts(3)}))
+	require.NoError(t, ds.EndDef())
+
+	x, err := ds.Var("x")
+	require.NoError(t, err)
+	require.NoError(t, Write(x, []int16{1, 2, 3}))
+
+	temp, err := ds.Var("temp")
+	require.NoError(t, err)
+	require.NoError(t, Write(temp, []float32{10, 11, 12, 20, 21, 22}))
+	require.NoError(t, ds.Close())
+}
+
+func TestCreateAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.nc")
+	createTestDataset(t, path)
+
+	ds, err := Open(path)
+	require.NoError(t, err)
+	defer ds.Close()
+	assert.False(t, ds.InDefineMode())
+	assert.Equal(t, int64(2), ds.Header().NumRecs)
+
+	temp, err := ds.Var("temp")
+	require.NoError(t, err)
+	values, err := temp.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []float32{10, 11, 12, 20, 21, 22}, values)
+
+	slab, err := SlabValues[float32](temp, types.Slab{Start: []int64{1, 1}, Count: []int64{1, 2}})
+	require.NoError(t, err)
+	assert.Equal(t, []float32{21, 22}, slab)
+
+	assert.Equal(t, ErrReadOnly, ds.Redef())
+	assert.Equal(t, ErrReadOnly, Write(temp, []float32{1, 2, 3}))
+
+	_, err = ds.Var("other")
+	assert.EqualError(t, err, "Unknown variable `other`")
+}
+
+func TestRedefMovesData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.nc")
+	createTestDataset(t, path)
+
+	ds, err := OpenFile(path, os.O_RDWR)
+	require.NoError(t, err)
+	require.NoError(t, ds.Redef())
+	require.NoError(t, ds.AddDim("y", 2))
+	require.NoError(t, ds.AddVar("y", types.Double, "y"))
+	require.NoError(t, ds.AddVar("time", types.Double, "time"))
+	require.NoError(t, ds.SetAttr(types.Attr{Name: "version", Val: types.Shorts(2)}))
+
+	x, err := ds.Var("x")
+	require.NoError(t, err)
+	_, err = x.Read()
+	assert.Equal(t, ErrDefineMode, err)
+	assert.Equal(t, ErrDefineMode, Write(x, []int16{1, 2, 3}))
+
+	// Close writes the header
+	require.NoError(t, ds.Close())
+
+	ds, err = Open(path)
+	require.NoError(t, err)
+	defer ds.Close()
+	assert.True(t, ds.Header().Attrs.Has("version"))
+
+	x, err = ds.Var("x")
+	require.NoError(t, err)
+	xs, err := Values[int16](x)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{1, 2, 3}, xs)
+
+	temp, err := ds.Var("temp")
+	require.NoError(t, err)
+	values, err := Values[float32](temp)
+	require.NoError(t, err)
+	assert.Equal(t, []float32{10, 11, 12, 20, 21, 22}, values)
+
+	tm, err := ds.Var("time")
+	require.NoError(t, err)
+	tms, err := Values[float64](tm)
+	require.NoError(t, err)
+	// new variables are pre-filled
+	assert.Equal(t, []float64{types.FillDouble, types.FillDouble}, tms)
+
+	y, err := ds.Var("y")
+	require.NoError(t, err)
+	ys, err := Values[float64](y)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{types.FillDouble, types.FillDouble}, ys)
+}
+
+func TestRedefKeepsRecords(t *testing.T) {
+	ds, err := Create(filepath.Join(t.TempDir(), "test.nc"), nil)
+	require.NoError(t, err)
+	defer ds.Close()
+	require.NoError(t, ds.AddDim("t", 0))
+	require.NoError(t, ds.AddVar("b", types.Byte, "t"))
+	require.NoError(t, ds.EndDef())
+
+	b, err := ds.Var("b")
+	require.NoError(t, err)
+	require.NoError(t, Write(b, []byte{1, 2, 3}))
+
+	// records written in this session are moved too
+	require.NoError(t, ds.Redef())
+	require.NoError(t, ds.SetAttr(types.Attr{Name: "title", Val: types.Text("records")}))
+	require.NoError(t, ds.AddVar("c", types.Int, "t"))
+	require.NoError(t, ds.EndDef())
+
+	bs, err := Values[byte](b)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, bs)
+	c, err := ds.Var("c")
+	require.NoError(t, err)
+	cs, err := Values[int32](c)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{types.FillInt, types.FillInt, types.FillInt}, cs)
+}
+
+func TestWriteErrors(t *testing.T) {
+	ds, err := Create(filepath.Join(t.TempDir(), "test.nc"), nil)
+	require.NoError(t, err)
+	defer ds.Close()
+	require.NoError(t, ds.AddDim("x", 3))
+	require.NoError(t, ds.AddVar("c", types.Char, "x"))
+	require.NoError(t, ds.AddVar("next", types.Int, "x"))
+	require.NoError(t, ds.EndDef())
+
+	c, err := ds.Var("c")
+	require.NoError(t, err)
+	assert.EqualError(t, Write(c, []float64{1, 2, 3}), "Cannot write values of type float64 in variable `c` of type NC_CHAR")
+	assert.EqualError(t, Write(c, []byte("abcdefgh")), "Variable `c` has 3 values, got 8")
+	assert.EqualError(t,
+		WriteSlab(c, types.Slab{Start: []int64{0}, Count: []int64{2}}, []int16{1, 2}),
+		"Cannot write values of type int16 in variable `c` of type NC_CHAR",
+	)
+
+	next, err := ds.Var("next")
+	require.NoError(t, err)
+	values, err := Values[int32](next)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{types.FillInt, types.FillInt, types.FillInt}, values)
+}
+
+func TestDefineErrors(t *testing.T) {
+	ds, err := Create(filepath.Join(t.TempDir(), "test.nc"), nil)
+	require.NoError(t, err)
+	defer ds.Close()
+
+	require.NoError(t, ds.AddDim("time", 0))
+	assert.EqualError(t, ds.AddDim("time", 2), "Dimension `time` already exists")
+	assert.EqualError(t, ds.AddDim("rec", 0), "Unlimited dimension `time` already exists")
+	require.NoError(t, ds.AddDim("x", 2))
+	assert.EqualError(t, ds.AddVar("v", types.Float, "x", "time"), "Unlimited dimension `time` must be the first one of variable `v`")
+	assert.EqualError(t, ds.AddVar("v", types.Float, "z"), "Unknown dimension `z`")
+	assert.EqualError(t, ds.AddVar("v", types.UInt, "x"), "Type NC_UINT of `v` is not supported by version 2")
+	assert.EqualError(t, ds.SetVarAttr("v", types.Attr{Name: "a"}), "Unknown variable `v`")
+	assert.Equal(t, ErrDefineMode, ds.Redef())
+
+	require.NoError(t, ds.EndDef())
+	assert.Equal(t, ErrDataMode, ds.EndDef())
+	assert.Equal(t, ErrDataMode, ds.AddDim("y", 2))
+}
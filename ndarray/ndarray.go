@@ -0,0 +1,336 @@
+// Package ndarray implements N-dimensional arrays
+// holding values of netcdf variables.
+package ndarray
+
+import (
+	"fmt"
+
+	"github.com/parro-it/ncdf/types"
+)
+
+// Array is an N-dimensional array of values of type T.
+//
+// Values are stored in a flat slice: the value at index
+// idx is at position Offset + sum(idx[i] * Strides[i]).
+// Arrays returned by Slice and Transpose are views
+// that share values with the array they come from.
+//
+// A zero dimensional array contains a single value.
+type Array[T types.BaseType] struct {
+	data    []T
+	offset  int
+	shape   []int
+	strides []int
+	dims    []string
+}
+
+// Range selects indexes Start, Start+Step, ... up
+// to Stop (excluded) along a dimension. A zero Step
+// is equivalent to 1.
+type Range struct {
+	Start, Stop, Step int
+}
+
+// New returns an array of the given shape, with all values
+// set to zero. dims optionally names each dimension.
+func New[T types.BaseType](shape []int, dims ...string) *Array[T] {
+	a, _ := FromSlice(make([]T, product(shape)), shape, dims...)
+	return a
+}
+
+// FromSlice returns an array of the given shape whose
+// values are data, in row-major order. The array
+// shares data with the slice.
+// dims optionally names each dimension.
+func FromSlice[T types.BaseType](data []T, shape []int, dims ...string) (*Array[T], error) {
+	for _, n := range shape {
+		if n < 0 {
+			return nil, fmt.Errorf("Negative length in shape %v", shape)
+		}
+	}
+	if len(data) != product(shape) {
+		return nil, fmt.Errorf("Data of length %d does not match shape %v", len(data), shape)
+	}
+	if len(dims) != 0 && len(dims) != len(shape) {
+		return nil, fmt.Errorf("Array of shape %v needs %d dimension names, got %d", shape, len(shape), len(dims))
+	}
+	return &Array[T]{
+		data:    data,
+		shape:   append([]int{}, shape...),
+		strides: rowMajor(shape),
+		dims:    names(dims, len(shape)),
+	}, nil
+}
+
+// FromStrided returns an array of the given shape whose
+// values are stored in data at the given strides.
+// It's used to wrap memory arrays filled using a
+// types.Slab IMap.
+func FromStrided[T types.BaseType](data []T, shape, strides []int, dims ...string) (*Array[T], error) {
+	if len(strides) != len(shape) {
+		return nil, fmt.Errorf("Array of shape %v needs %d strides, got %d", shape, len(shape), len(strides))
+	}
+	if len(dims) != 0 && len(dims) != len(shape) {
+		return nil, fmt.Errorf("Array of shape %v needs %d dimension names, got %d", shape, len(shape), len(dims))
+	}
+	last := 0
+	for i, n := range shape {
+		if n < 0 || strides[i] < 0 {
+			return nil, fmt.Errorf("Negative length or stride in shape %v, strides %v", shape, strides)
+		}
+		if n > 0 {
+			last += (n - 1) * strides[i]
+		}
+	}
+	if product(shape) > 0 && last >= len(data) {
+		return nil, fmt.Errorf("Data of length %d does not match shape %v and strides %v", len(data), shape, strides)
+	}
+	return &Array[T]{
+		data:    data,
+		shape:   append([]int{}, shape...),
+		strides: append([]int{}, strides...),
+		dims:    names(dims, len(shape)),
+	}, nil
+}
+
+// Shape returns the length of each dimension.
+func (a *Array[T]) Shape() []int {
+	return append([]int{}, a.shape...)
+}
+
+// Strides returns, for each dimension, the distance
+// in the underlying slice between consecutive values.
+func (a *Array[T]) Strides() []int {
+	return append([]int{}, a.strides...)
+}
+
+// Dims returns the names of the dimensions.
+// Unnamed dimensions have an empty name.
+func (a *Array[T]) Dims() []string {
+	return append([]string{}, a.dims...)
+}
+
+// Dim returns the position of the dimension
+// called name, or -1 if there's none.
+func (a *Array[T]) Dim(name string) int {
+	for i, d := range a.dims {
+		if d == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// NDim returns the number of dimensions.
+func (a *Array[T]) NDim() int {
+	return len(a.shape)
+}
+
+// Len returns the number of values.
+func (a *Array[T]) Len() int {
+	return product(a.shape)
+}
+
+// pos returns the position in a.data of the value at idx.
+// It panics if idx is out of range, like indexing a slice.
+func (a *Array[T]) pos(idx []int) int {
+	if len(idx) != len(a.shape) {
+		panic(fmt.Sprintf("ndarray: %d indexes used on array of %d dimensions", len(idx), len(a.shape)))
+	}
+	p := a.offset
+	for i, x := range idx {
+		if x < 0 || x >= a.shape[i] {
+			panic(fmt.Sprintf("ndarray: index %d out of range [0:%d] of dimension %d", x, a.shape[i], i))
+		}
+		p += x * a.strides[i]
+	}
+	return p
+}
+
+// At returns the value at idx.
+// It panics if idx is out of range.
+func (a *Array[T]) At(idx ...int) T {
+	return a.data[a.pos(idx)]
+}
+
+// Set sets the value at idx to val.
+// It panics if idx is out of range.
+func (a *Array[T]) Set(val T, idx ...int) {
+	a.data[a.pos(idx)] = val
+}
+
+// Slice returns a view of the values selected by ranges,
+// one for each dimension. Values are not copied.
+func (a *Array[T]) Slice(ranges ...Range) (*Array[T], error) {
+	if len(ranges) != len(a.shape) {
+		return nil, fmt.Errorf("Array of %d dimensions needs %d ranges, got %d", len(a.shape), len(a.shape), len(ranges))
+	}
+	res := &Array[T]{
+		data:    a.data,
+		offset:  a.offset,
+		shape:   make([]int, len(a.shape)),
+		strides: make([]int, len(a.shape)),
+		dims:    a.Dims(),
+	}
+	for i, r := range ranges {
+		if r.Step == 0 {
+			r.Step = 1
+		}
+		if r.Step < 0 || r.Start < 0 || r.Stop > a.shape[i] || r.Start > r.Stop {
+			return nil, fmt.Errorf("Invalid range %d:%d:%d for dimension %d of length %d", r.Start, r.Stop, r.Step, i, a.shape[i])
+		}
+		res.offset += r.Start * a.strides[i]
+		res.shape[i] = (r.Stop - r.Start + r.Step - 1) / r.Step
+		res.strides[i] = a.strides[i] * r.Step
+	}
+	return res, nil
+}
+
+// Index returns a view of the values at index
+// idx along dimension dim, with one dimension less.
+func (a *Array[T]) Index(dim, idx int) (*Array[T], error) {
+	if dim < 0 || dim >= len(a.shape) {
+		return nil, fmt.Errorf("Dimension %d out of range of array of %d dimensions", dim, len(a.shape))
+	}
+	if idx < 0 || idx >= a.shape[dim] {
+		return nil, fmt.Errorf("Index %d out of range for dimension %d of length %d", idx, dim, a.shape[dim])
+	}
+	remove := func(s []int) []int {
+		return append(append([]int{}, s[:dim]...), s[dim+1:]...)
+	}
+	return &Array[T]{
+		data:    a.data,
+		offset:  a.offset + idx*a.strides[dim],
+		shape:   remove(a.shape),
+		strides: remove(a.strides),
+		dims:    append(append([]string{}, a.dims[:dim]...), a.dims[dim+1:]...),
+	}, nil
+}
+
+// Each calls fn for each value, in row-major order.
+// idx is reused between calls, and must
+// not be modified or retained by fn.
+func (a *Array[T]) Each(fn func(idx []int, val T)) {
+	if a.Len() == 0 {
+		return
+	}
+	idx := make([]int, len(a.shape))
+	p := a.offset
+	for {
+		fn(idx, a.data[p])
+
+		i := len(idx) - 1
+		for ; i >= 0; i-- {
+			idx[i]++
+			p += a.strides[i]
+			if idx[i] < a.shape[i] {
+				break
+			}
+			p -= idx[i] * a.strides[i]
+			idx[i] = 0
+		}
+		if i < 0 {
+			return
+		}
+	}
+}
+
+// Values returns a copy of all values, in row-major order.
+func (a *Array[T]) Values() []T {
+	res := make([]T, 0, a.Len())
+	a.Each(func(idx []int, val T) {
+		res = append(res, val)
+	})
+	return res
+}
+
+// IsContiguous returns whether values are stored
+// in row-major order without gaps.
+func (a *Array[T]) IsContiguous() bool {
+	expected := rowMajor(a.shape)
+	for i, n := range a.shape {
+		if n > 1 && a.strides[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy returns a contiguous copy of the array.
+func (a *Array[T]) Copy() *Array[T] {
+	res, _ := FromSlice(a.Values(), a.shape, a.dims...)
+	return res
+}
+
+// Reshape returns an array with the same values in row-major
+// order and a new shape. The returned array shares values
+// with a when a is contiguous. Dimension names are dropped.
+func (a *Array[T]) Reshape(shape ...int) (*Array[T], error) {
+	if product(shape) != a.Len() {
+		return nil, fmt.Errorf("Cannot reshape array of shape %v to %v", a.shape, shape)
+	}
+	if !a.IsContiguous() {
+		return FromSlice(a.Values(), shape)
+	}
+	return FromSlice(a.data[a.offset:a.offset+a.Len()], shape)
+}
+
+// Transpose returns a view of the array with dimensions
+// permuted: dimension i of the result is dimension axes[i]
+// of a. Without axes, the order of dimensions is reversed.
+func (a *Array[T]) Transpose(axes ...int) (*Array[T], error) {
+	n := len(a.shape)
+	if len(axes) == 0 {
+		for i := n - 1; i >= 0; i-- {
+			axes = append(axes, i)
+		}
+	}
+	if len(axes) != n {
+		return nil, fmt.Errorf("Array of %d dimensions needs %d axes, got %d", n, n, len(axes))
+	}
+	res := &Array[T]{
+		data:    a.data,
+		offset:  a.offset,
+		shape:   make([]int, n),
+		strides: make([]int, n),
+		dims:    make([]string, n),
+	}
+	seen := make([]bool, n)
+	for i, ax := range axes {
+		if ax < 0 || ax >= n || seen[ax] {
+			return nil, fmt.Errorf("Invalid axes %v for array of %d dimensions", axes, n)
+		}
+		seen[ax] = true
+		res.shape[i] = a.shape[ax]
+		res.strides[i] = a.strides[ax]
+		res.dims[i] = a.dims[ax]
+	}
+	return res, nil
+}
+
+func product(shape []int) int {
+	n := 1
+	for _, x := range shape {
+		n *= x
+	}
+	return n
+}
+
+// rowMajor returns the strides of a
+// contiguous array of the given shape.
+func rowMajor(shape []int) []int {
+	strides := make([]int, len(shape))
+	step := 1
+	for i := len(shape) - 1; i >= 0; i-- {
+		strides[i] = step
+		step *= shape[i]
+	}
+	return strides
+}
+
+func names(dims []string, n int) []string {
+	if len(dims) == 0 {
+		return make([]string, n)
+	}
+	return append([]string{}, dims...)
+}
@@ -0,0 +1,115 @@
+package ndarray
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// grid returns a 2x3 array whose values are y*10 + x.
+func grid(t *testing.T) *Array[int32] {
+	a, err := FromSlice([]int32{0, 1, 2, 10, 11, 12}, []int{2, 3}, "y", "x")
+	require.NoError(t, err)
+	return a
+}
+
+func TestFromSlice(t *testing.T) {
+	a := grid(t)
+	assert.Equal(t, []int{2, 3}, a.Shape())
+	assert.Equal(t, []int{3, 1}, a.Strides())
+	assert.Equal(t, []string{"y", "x"}, a.Dims())
+	assert.Equal(t, 1, a.Dim("x"))
+	assert.Equal(t, -1, a.Dim("z"))
+	assert.Equal(t, 6, a.Len())
+	assert.Equal(t, int32(12), a.At(1, 2))
+
+	a.Set(42, 0, 1)
+	assert.Equal(t, int32(42), a.At(0, 1))
+	assert.Panics(t, func() { a.At(2, 0) })
+	assert.Panics(t, func() { a.At(0) })
+
+	_, err := FromSlice([]int32{1, 2}, []int{3})
+	assert.EqualError(t, err, "Data of length 2 does not match shape [3]")
+	_, err = FromSlice([]int32{1, 2}, []int{2}, "x", "y")
+	assert.EqualError(t, err, "Array of shape [2] needs 1 dimension names, got 2")
+
+	scalar := New[float64](nil)
+	assert.Equal(t, 1, scalar.Len())
+	assert.Equal(t, []float64{0}, scalar.Values())
+}
+
+func TestSlice(t *testing.T) {
+	a := grid(t)
+	s, err := a.Slice(Range{0, 2, 1}, Range{0, 3, 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 2}, s.Shape())
+	assert.Equal(t, []int32{0, 2, 10, 12}, s.Values())
+	assert.False(t, s.IsContiguous())
+
+	// views share values
+	s.Set(-1, 1, 1)
+	assert.Equal(t, int32(-1), a.At(1, 2))
+
+	row, err := a.Index(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x"}, row.Dims())
+	assert.Equal(t, []int32{10, 11, -1}, row.Values())
+	assert.True(t, row.IsContiguous())
+
+	_, err = a.Slice(Range{0, 3, 1}, Range{0, 3, 1})
+	assert.EqualError(t, err, "Invalid range 0:3:1 for dimension 0 of length 2")
+	_, err = a.Index(2, 0)
+	assert.EqualError(t, err, "Dimension 2 out of range of array of 2 dimensions")
+}
+
+func TestEach(t *testing.T) {
+	a := grid(t)
+	var idxs [][]int
+	var values []int32
+	a.Each(func(idx []int, val int32) {
+		idxs = append(idxs, append([]int{}, idx...))
+		values = append(values, val)
+	})
+	assert.Equal(t, [][]int{{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}}, idxs)
+	assert.Equal(t, []int32{0, 1, 2, 10, 11, 12}, values)
+}
+
+func TestReshapeTranspose(t *testing.T) {
+	a := grid(t)
+	tr, err := a.Transpose()
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2}, tr.Shape())
+	assert.Equal(t, []string{"x", "y"}, tr.Dims())
+	assert.Equal(t, []int32{0, 10, 1, 11, 2, 12}, tr.Values())
+	assert.Equal(t, int32(12), tr.At(2, 1))
+
+	r, err := tr.Reshape(6)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 10, 1, 11, 2, 12}, r.Values())
+
+	// contiguous arrays are reshaped without copying
+	r, err = a.Reshape(3, 2)
+	require.NoError(t, err)
+	r.Set(7, 0, 0)
+	assert.Equal(t, int32(7), a.At(0, 0))
+	assert.Equal(t, []string{"", ""}, r.Dims())
+
+	_, err = a.Reshape(4)
+	assert.EqualError(t, err, "Cannot reshape array of shape [2 3] to [4]")
+	_, err = a.Transpose(0, 0)
+	assert.EqualError(t, err, "Invalid axes [0 0] for array of 2 dimensions")
+
+	c := tr.Copy()
+	assert.True(t, c.IsContiguous())
+	assert.Equal(t, tr.Values(), c.Values())
+}
+
+func TestFromStrided(t *testing.T) {
+	a, err := FromStrided([]int32{0, 10, 1, 11, 2, 12}, []int{2, 3}, []int{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 1, 2, 10, 11, 12}, a.Values())
+
+	_, err = FromStrided([]int32{0, 1}, []int{2, 3}, []int{1, 2})
+	assert.EqualError(t, err, "Data of length 2 does not match shape [2 3] and strides [1 2]")
+}
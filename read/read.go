@@ -4,9 +4,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"unsafe"
 
+	"github.com/parro-it/ncdf/ndarray"
 	"github.com/parro-it/ncdf/ordmap"
 	"github.com/parro-it/ncdf/types"
 )
@@ -23,44 +25,73 @@ func Header(fd io.ReadSeeker) (*types.File, error) {
 		return nil, err
 	}
 
-	if f.NumRecs, err = readSingleValue[int32](fd); err != nil {
+	if f.NumRecs, err = readCount(f.Version, fd); err != nil {
 		return nil, err
 	}
+	if f.Version.CountSize() == 4 && f.NumRecs == math.MaxUint32 {
+		f.NumRecs = types.Streaming
+	}
 
-	if f.Dimensions, err = readDimensions(fd); err != nil {
+	if f.Dimensions, err = readDimensions(f.Version, fd); err != nil {
 		return nil, err
 	}
 
-	if f.Attrs, err = readAttributes(fd); err != nil {
+	if f.Attrs, err = readAttributes(f.Version, fd); err != nil {
 		return nil, err
 	}
 
-	if f.Vars, err = readVars(f.Dimensions, fd); err != nil {
+	if f.Vars, err = readVars(f.Version, f.Dimensions, fd); err != nil {
 		return nil, err
 	}
 
+	if f.NumRecs == types.Streaming {
+		if f.NumRecs, err = streamingNumRecs(f, fd); err != nil {
+			return nil, err
+		}
+	}
+
 	return f, nil
 }
 
-func readDimensions(fd io.ReadSeeker) ([]types.Dimension, error) {
+// streamingNumRecs computes the number of records
+// of a file written in streaming mode, from
+// the size of the file.
+func streamingNumRecs(f *types.File, fd io.ReadSeeker) (int64, error) {
+	recVars := f.RecordVars()
+	recSize := f.RecSize()
+	if len(recVars) == 0 || recSize == 0 {
+		return 0, nil
+	}
+	end, err := fd.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	begin := int64(recVars[0].Offset)
+	if end <= begin {
+		return 0, nil
+	}
+	return (end - begin) / recSize, nil
+}
+
+func readDimensions(ver types.Version, fd io.ReadSeeker) ([]types.Dimension, error) {
 	t, err := readTag(fd)
 	if err != nil {
 		return nil, err
 	}
 	if t == types.ZeroTag {
-		_, err := sectionNotPresent[types.Dimension](fd)
+		_, err := sectionNotPresent[types.Dimension](ver, fd)
 		return nil, err
 	}
 	if t != types.DimensionTag {
 		return nil, fmt.Errorf("Expected DimensionTag, got %s", t.String())
 	}
-	lst, err := readListOfObjects(fd, func() (d types.Dimension, err error) {
+	lst, err := readListOfObjects(ver, fd, func() (d types.Dimension, err error) {
 
-		if d.Name, err = readString(fd); err != nil {
+		if d.Name, err = readString(ver, fd); err != nil {
 			return d, err
 		}
 
-		if d.Len, err = readSingleValue[int32](fd); err != nil {
+		if d.Len, err = readCount(ver, fd); err != nil {
 			return d, err
 		}
 
@@ -73,21 +104,21 @@ func readDimensions(fd io.ReadSeeker) ([]types.Dimension, error) {
 	return lst, nil
 }
 
-func sectionNotPresent[T any](fd io.ReadSeeker) (ordmap.OrderedMap[T, string], error) {
+func sectionNotPresent[T any](ver types.Version, fd io.ReadSeeker) (ordmap.OrderedMap[T, string], error) {
 	var res ordmap.OrderedMap[T, string]
-	t2, err := readTag(fd)
+	n, err := readCount(ver, fd)
 	if err != nil {
 		return res, err
 	}
 
-	if t2 != types.ZeroTag {
-		return res, fmt.Errorf("Expected ZeroTag, got %s", t2.String())
+	if n != 0 {
+		return res, fmt.Errorf("Expected ZeroTag, got %d", n)
 	}
 
 	return res, nil
 }
 
-func readAttributes(fd io.ReadSeeker) (ordmap.OrderedMap[types.Attr, string], error) {
+func readAttributes(ver types.Version, fd io.ReadSeeker) (ordmap.OrderedMap[types.Attr, string], error) {
 	var res ordmap.OrderedMap[types.Attr, string]
 
 	t, err := readTag(fd)
@@ -96,24 +127,25 @@ func readAttributes(fd io.ReadSeeker) (ordmap.OrderedMap[types.Attr, string], er
 	}
 
 	if t == types.ZeroTag {
-		return sectionNotPresent[types.Attr](fd)
+		return sectionNotPresent[types.Attr](ver, fd)
 	}
 
 	if t != types.AttributeTag {
 		return res, fmt.Errorf("Expected AttributeTag, got %s", t.String())
 	}
 
-	lst, err := readListOfObjects(fd, func() (a types.Attr, err error) {
+	lst, err := readListOfObjects(ver, fd, func() (a types.Attr, err error) {
 
-		if a.Name, err = readString(fd); err != nil {
+		if a.Name, err = readString(ver, fd); err != nil {
 			return
 		}
 
-		if a.Type, err = readSingleValue[types.Type](fd); err != nil {
+		var t types.Type
+		if t, err = readSingleValue[types.Type](fd); err != nil {
 			return
 		}
 
-		if a.Val, err = readAttributeValue(a, fd); err != nil {
+		if a.Val, err = readAttributeValue(ver, t, fd); err != nil {
 			return
 		}
 
@@ -128,31 +160,34 @@ func readAttributes(fd io.ReadSeeker) (ordmap.OrderedMap[types.Attr, string], er
 	return res, nil
 }
 
-func readVars(dims []types.Dimension, fd io.ReadSeeker) (ordmap.OrderedMap[types.Var, string], error) {
+func readVars(ver types.Version, dims []types.Dimension, fd io.ReadSeeker) (ordmap.OrderedMap[types.Var, string], error) {
 	var res ordmap.OrderedMap[types.Var, string]
 	t, err := readTag(fd)
 	if err != nil {
 		return res, err
 	}
 	if t == types.ZeroTag {
-		return sectionNotPresent[types.Var](fd)
+		return sectionNotPresent[types.Var](ver, fd)
 	}
 
 	if t != types.VariableTag {
 		return res, fmt.Errorf("Expected VariableTag, got %s", t.String())
 	}
 
-	lst, err := readListOfObjects(fd, func() (v types.Var, err error) {
+	lst, err := readListOfObjects(ver, fd, func() (v types.Var, err error) {
 
-		if v.Name, err = readString(fd); err != nil {
+		if v.Name, err = readString(ver, fd); err != nil {
 			return v, err
 		}
 
-		v.Dimensions, err = readListOfObjects(fd, func() (*types.Dimension, error) {
-			id, err := readSingleValue[int32](fd)
+		v.Dimensions, err = readListOfObjects(ver, fd, func() (*types.Dimension, error) {
+			id, err := readCount(ver, fd)
 			if err != nil {
 				return nil, err
 			}
+			if id >= int64(len(dims)) {
+				return nil, fmt.Errorf("Invalid dimension id %d", id)
+			}
 			return &dims[id], nil
 		})
 
@@ -160,18 +195,18 @@ func readVars(dims []types.Dimension, fd io.ReadSeeker) (ordmap.OrderedMap[types
 			return v, err
 		}
 
-		if v.Attrs, err = readAttributes(fd); err != nil {
+		if v.Attrs, err = readAttributes(ver, fd); err != nil {
 			return v, err
 		}
 		if v.Type, err = readSingleValue[types.Type](fd); err != nil {
 			return v, err
 		}
 
-		if v.Size, err = readSingleValue[int32](fd); err != nil {
+		if v.Size, err = readCount(ver, fd); err != nil {
 			return v, err
 		}
 
-		if v.Offset, err = readSingleValue[uint64](fd); err != nil {
+		if v.Offset, err = readOffset(ver, fd); err != nil {
 			return v, err
 		}
 		return
@@ -186,8 +221,8 @@ func readVars(dims []types.Dimension, fd io.ReadSeeker) (ordmap.OrderedMap[types
 	return res, nil
 }
 
-func readListOfValues[T types.BaseType](fd io.ReadSeeker) ([]T, error) {
-	nelems, err := readSingleValue[int32](fd)
+func readListOfValues[T types.BaseType](ver types.Version, fd io.ReadSeeker) ([]T, error) {
+	nelems, err := readCount(ver, fd)
 	if err != nil {
 		var empty []T
 		return empty, err
@@ -196,7 +231,7 @@ func readListOfValues[T types.BaseType](fd io.ReadSeeker) ([]T, error) {
 	var res []T
 	var val T
 
-	for i := int32(0); i < nelems; i++ {
+	for i := int64(0); i < nelems; i++ {
 		val, err = readSingleValue[T](fd)
 		if err != nil {
 			var empty []T
@@ -221,45 +256,56 @@ func readListOfValues[T types.BaseType](fd io.ReadSeeker) ([]T, error) {
 	return res, nil
 }
 
-// TODO: add support for multiple values
-func readAttributeValue(a types.Attr, fd io.ReadSeeker) (interface{}, error) {
-	t := a.Type
-	if t == types.Double {
-		return readListOfValues[float64](fd)
-	}
-
-	if t == types.Short {
-		return readListOfValues[int16](fd)
-	}
-
-	if t == types.Int {
-		return readListOfValues[int32](fd)
-	}
-
-	if t == types.Byte {
-		return readListOfValues[byte](fd)
-	}
+// readAttributeValue reads all values of an attribute of type t.
+func readAttributeValue(ver types.Version, t types.Type, fd io.ReadSeeker) (types.Value, error) {
+	if !ver.Supports(t) {
+		return types.Value{}, fmt.Errorf("Unsupported type <%s>", t)
+	}
+
+	switch t {
+	case types.Char, types.Byte, types.UByte:
+		return readValue[byte](ver, t, fd)
+	case types.Short:
+		return readValue[int16](ver, t, fd)
+	case types.Int:
+		return readValue[int32](ver, t, fd)
+	case types.Float:
+		return readValue[float32](ver, t, fd)
+	case types.Double:
+		return readValue[float64](ver, t, fd)
+	case types.UShort:
+		return readValue[uint16](ver, t, fd)
+	case types.UInt:
+		return readValue[uint32](ver, t, fd)
+	case types.Int64:
+		return readValue[int64](ver, t, fd)
+	case types.UInt64:
+		return readValue[uint64](ver, t, fd)
+	}
+
+	return types.Value{}, fmt.Errorf("Unsupported type <%s>", t)
+}
 
-	if t == types.Float {
-		return readListOfValues[float32](fd)
+func readValue[T types.BaseType](ver types.Version, t types.Type, fd io.ReadSeeker) (types.Value, error) {
+	values, err := readListOfValues[T](ver, fd)
+	if err != nil {
+		return types.Value{}, err
 	}
-
-	if t == types.Char {
-		return readString(fd)
+	if values == nil {
+		values = []T{}
 	}
-
-	return nil, fmt.Errorf("Unsupported type <%s>", t)
+	return types.NewValue(t, values)
 }
 
-func readListOfObjects[T any](fd io.ReadSeeker, fn func() (T, error)) (list []T, err error) {
-	len, err := readSingleValue[int32](fd)
+func readListOfObjects[T any](ver types.Version, fd io.ReadSeeker, fn func() (T, error)) (list []T, err error) {
+	len, err := readCount(ver, fd)
 	if err != nil {
 		return nil, err
 	}
 
 	list = make([]T, len)
 
-	for i := int32(0); i < len; i++ {
+	for i := int64(0); i < len; i++ {
 		list[i], err = fn()
 		if err != nil {
 			return nil, err
@@ -269,8 +315,8 @@ func readListOfObjects[T any](fd io.ReadSeeker, fn func() (T, error)) (list []T,
 	return list, nil
 }
 
-func readString(fd io.ReadSeeker) (string, error) {
-	v, err := readListOfValues[byte](fd)
+func readString(ver types.Version, fd io.ReadSeeker) (string, error) {
+	v, err := readListOfValues[byte](ver, fd)
 	if err != nil {
 		return "", err
 	}
@@ -286,6 +332,34 @@ func readSingleValue[T any](fd io.ReadSeeker) (T, error) {
 	return val, nil
 }
 
+// readCount reads a non negative count,
+// using 64 bits for CDF-5 files and 32 bits
+// otherwise.
+func readCount(ver types.Version, fd io.ReadSeeker) (int64, error) {
+	if ver.CountSize() == 8 {
+		return readSingleValue[int64](fd)
+	}
+	n, err := readSingleValue[uint32](fd)
+	return int64(n), err
+}
+
+// readOffset reads the begin offset of a variable,
+// using a signed 32 bits integer for CDF-1 files
+// and 64 bits otherwise.
+func readOffset(ver types.Version, fd io.ReadSeeker) (uint64, error) {
+	if ver.OffsetSize() == 8 {
+		return readSingleValue[uint64](fd)
+	}
+	n, err := readSingleValue[int32](fd)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("Invalid offset %d", n)
+	}
+	return uint64(n), nil
+}
+
 func readTag(fd io.ReadSeeker) (types.Tag, error) {
 	var buf [4]byte
 	if err := binary.Read(fd, binary.BigEndian, &buf); err != nil {
@@ -311,14 +385,163 @@ func HeaderFromDisk(file string) (*types.File, error) {
 	return f, nil
 }
 
-// VarData ...
-func VarData[T types.BaseType](v types.Var, fd io.ReadSeeker) ([]T, error) {
-	if _, err := fd.Seek(int64(v.Offset), io.SeekStart); err != nil {
+// VarData reads all values of variable v of file f.
+// Values of record variables are read
+// from all the f.NumRecs records.
+// It returns an error if values of v cannot be
+// stored in values of type T.
+func VarData[T types.BaseType](f *types.File, v types.Var, fd io.ReadSeeker) ([]T, error) {
+	return Slab[T](f, v, f.WholeSlab(v), fd)
+}
+
+// VarDataAny reads all values of variable v of file f,
+// returning them in a slice whose element type
+// is choosen from v.Type.
+// NC_CHAR, NC_BYTE and NC_UBYTE values are returned as []byte.
+func VarDataAny(f *types.File, v types.Var, fd io.ReadSeeker) (interface{}, error) {
+	return SlabAny(f, v, f.WholeSlab(v), fd)
+}
+
+// SlabAny reads values of variable v of file f
+// selected by s, returning them in a slice whose element type
+// is choosen from v.Type, like VarDataAny.
+func SlabAny(f *types.File, v types.Var, s types.Slab, fd io.ReadSeeker) (interface{}, error) {
+	switch v.Type {
+	case types.Byte, types.UByte, types.Char:
+		return Slab[byte](f, v, s, fd)
+	case types.Short:
+		return Slab[int16](f, v, s, fd)
+	case types.Int:
+		return Slab[int32](f, v, s, fd)
+	case types.Float:
+		return Slab[float32](f, v, s, fd)
+	case types.Double:
+		return Slab[float64](f, v, s, fd)
+	case types.UShort:
+		return Slab[uint16](f, v, s, fd)
+	case types.UInt:
+		return Slab[uint32](f, v, s, fd)
+	case types.Int64:
+		return Slab[int64](f, v, s, fd)
+	case types.UInt64:
+		return Slab[uint64](f, v, s, fd)
+	}
+	return nil, fmt.Errorf("Unsupported type <%s>", v.Type)
+}
+
+// Slab reads values of variable v of file f
+// selected by s. Only the byte ranges containing
+// selected values are read from fd.
+// Values are returned in the order given by s.IMap,
+// or in row-major order of s.Count when s.IMap is nil.
+// It returns an error if values of v cannot be
+// stored in values of type T.
+func Slab[T types.BaseType](f *types.File, v types.Var, s types.Slab, fd io.ReadSeeker) ([]T, error) {
+	if !types.IsValueType[T](v.Type) {
+		var empty T
+		return nil, fmt.Errorf("Cannot read values of variable `%s` of type %s as %T", v.Name, v.Type, empty)
+	}
+	if err := f.CheckSlab(v, s, false); err != nil {
 		return nil, err
 	}
-	data := make([]T, v.Size)
-	if err := binary.Read(fd, binary.BigEndian, &data); err != nil {
+	data := make([]T, s.MemLen())
+	var buf []T
+	err := f.SlabRuns(v, s, func(r types.Run) error {
+		if _, err := fd.Seek(r.Offset, io.SeekStart); err != nil {
+			return err
+		}
+		if r.Step == 1 {
+			return binary.Read(fd, binary.BigEndian, data[r.Index:r.Index+r.Len])
+		}
+		if int64(cap(buf)) < r.Len {
+			buf = make([]T, r.Len)
+		}
+		buf = buf[:r.Len]
+		if err := binary.Read(fd, binary.BigEndian, buf); err != nil {
+			return err
+		}
+		for i, val := range buf {
+			data[r.Index+int64(i)*r.Step] = val
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 	return data, nil
 }
+
+// VarDataMasked reads all values of variable v of file f
+// like VarData, and also returns a mask that reports, for
+// each value, whether it's a fill or missing value.
+// See types.Var.MissingFunc for the rules used.
+func VarDataMasked[T types.BaseType](f *types.File, v types.Var, fd io.ReadSeeker) ([]T, []bool, error) {
+	return SlabMasked[T](f, v, f.WholeSlab(v), fd)
+}
+
+// SlabMasked reads values of variable v of file f
+// selected by s like Slab, and also returns a mask
+// that reports, for each value, whether it's a fill
+// or missing value.
+func SlabMasked[T types.BaseType](f *types.File, v types.Var, s types.Slab, fd io.ReadSeeker) ([]T, []bool, error) {
+	data, err := Slab[T](f, v, s, fd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, types.Mask(v, data), nil
+}
+
+// VarDataUnpacked reads all values of variable v of file f
+// and unpacks them following the CF conventions, see
+// types.Unpack. Fill and missing values are returned as NaN.
+// T must match the type of unpacked values, see types.Var.Packing.
+func VarDataUnpacked[T types.Floating](f *types.File, v types.Var, fd io.ReadSeeker) ([]T, error) {
+	return SlabUnpacked[T](f, v, f.WholeSlab(v), fd)
+}
+
+// SlabUnpacked reads values of variable v of file f
+// selected by s, and unpacks them like VarDataUnpacked.
+func SlabUnpacked[T types.Floating](f *types.File, v types.Var, s types.Slab, fd io.ReadSeeker) ([]T, error) {
+	data, err := SlabAny(f, v, s, fd)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := types.NewValue(v.Type, data)
+	if err != nil {
+		return nil, err
+	}
+	return types.Unpack[T](v, packed)
+}
+
+// VarArray reads all values of variable v of file f
+// like VarData, returning them in an array shaped
+// and named after the dimensions of v.
+func VarArray[T types.BaseType](f *types.File, v types.Var, fd io.ReadSeeker) (*ndarray.Array[T], error) {
+	return SlabArray[T](f, v, f.WholeSlab(v), fd)
+}
+
+// SlabArray reads values of variable v of file f selected
+// by s like Slab, returning them in an array of shape
+// s.Count, named after the dimensions of v.
+// When s.IMap is not nil, it's used as the strides
+// of the array.
+func SlabArray[T types.BaseType](f *types.File, v types.Var, s types.Slab, fd io.ReadSeeker) (*ndarray.Array[T], error) {
+	data, err := Slab[T](f, v, s, fd)
+	if err != nil {
+		return nil, err
+	}
+	shape := make([]int, len(s.Count))
+	dims := make([]string, len(v.Dimensions))
+	for i, c := range s.Count {
+		shape[i] = int(c)
+		dims[i] = v.Dimensions[i].Name
+	}
+	if s.IMap == nil {
+		return ndarray.FromSlice(data, shape, dims...)
+	}
+	strides := make([]int, len(s.IMap))
+	for i, m := range s.IMap {
+		strides[i] = int(m)
+	}
+	return ndarray.FromStrided(data, shape, strides, dims...)
+}
@@ -1,11 +1,14 @@
 package read
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"testing"
 
 	"github.com/parro-it/ncdf/ordmap"
 	"github.com/parro-it/ncdf/types"
+	"github.com/parro-it/ncdf/write"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -36,9 +39,9 @@ func TestVarData(t *testing.T) {
 	require.NoError(t, err)
 	defer fd.Close()
 
-	values, err := VarData[float32](t2, fd)
+	values, err := VarData[float32](f, t2, fd)
 	require.NoError(t, err)
-	require.Equal(t, dim*4, len(values))
+	require.Equal(t, dim*int(f.NumRecs), len(values))
 
 	require.Equal(t, []float32{275.82614, 275.82596, 275.98535, 275.60385, 275.5405, 275.5339, 275.5177, 275.3091, 275.31165, 275.27158}, values[0:10])
 
@@ -65,11 +68,18 @@ func TestCheck(t *testing.T) {
 		}
 		assert.NoError(t, f.Version.Check())
 	})
+
+	t.Run("CDF-5", func(t *testing.T) {
+		f := &types.File{
+			Version: types.CDF5,
+		}
+		assert.NoError(t, f.Version.Check())
+	})
 	t.Run("NumRecs", func(t *testing.T) {
 		f, err := HeaderFromDisk("../fixtures/exampl2.nc")
 		assert.NoError(t, err)
 		require.NotNil(t, f)
-		assert.Equal(t, int32(1), f.NumRecs)
+		assert.Equal(t, int64(1), f.NumRecs)
 		//f.Close()
 
 	})
@@ -114,10 +124,193 @@ func TestCheck(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, types.Attr{
 			Name: "TITLE",
-			Val:  " OUTPUT FROM WRF V3.8.1 MODEL",
-			Type: types.Char,
+			Val:  types.Text(" OUTPUT FROM WRF V3.8.1 MODEL"),
 		}, f.Attrs.Get("TITLE"))
 
 	})
 
 }
+
+func TestHeaderCDF2(t *testing.T) {
+	f, err := HeaderFromDisk("../simple.nc")
+	require.NoError(t, err)
+	assert.Equal(t, types.CDF2, f.Version)
+	assert.Equal(t, []string{"red", "green", "blu"}, f.Vars.Keys())
+	assert.Equal(t, uint64(192), f.Vars.Get("red").Offset)
+	assert.Equal(t, uint64(592), f.Vars.Get("green").Offset)
+	assert.Equal(t, uint64(992), f.Vars.Get("blu").Offset)
+}
+
+func TestHeaderCDF1NegativeOffset(t *testing.T) {
+	f := (&types.File{
+		Version: types.CDF1,
+		Vars:    types.Vars{{Name: "v", Type: types.Int}}.Map(),
+	}).ComputeSizes()
+	var buf bytes.Buffer
+	require.NoError(t, write.Header(f, &buf))
+	// the begin offset of v is the last field of the header
+	copy(buf.Bytes()[buf.Len()-4:], []byte{0x80, 0, 0, 0})
+
+	_, err := Header(bytes.NewReader(buf.Bytes()))
+	assert.EqualError(t, err, "Invalid offset -2147483648")
+}
+
+// slabFile returns a file with a record variable
+// temp(time, y, x) whose values are t*100 + y*10 + x.
+func slabFile(t *testing.T) (*types.File, io.ReadSeeker) {
+	dims := []types.Dimension{{Name: "time", Len: 0}, {Name: "y", Len: 2}, {Name: "x", Len: 3}}
+	f := (&types.File{
+		Version:    types.CDF2,
+		Dimensions: dims,
+		Vars: types.Vars{
+			{Name: "temp", Type: types.Float, Dimensions: []*types.Dimension{&dims[0], &dims[1], &dims[2]}},
+			{Name: "time", Type: types.Double, Dimensions: []*types.Dimension{&dims[0]}},
+		}.Map(),
+	}).ComputeSizes()
+
+	var buf writerAt
+	require.NoError(t, write.Header(f, &buf))
+	var temp []float32
+	for tm := 0; tm < 4; tm++ {
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 3; x++ {
+				temp = append(temp, float32(tm*100+y*10+x))
+			}
+		}
+	}
+	require.NoError(t, write.VarData(f, f.Vars.Get("temp"), temp, &buf))
+	require.NoError(t, write.VarData(f, f.Vars.Get("time"), []float64{0, 1, 2, 3}, &buf))
+
+	return f, bytes.NewReader(buf.Bytes())
+}
+
+// writerAt is an in memory io.Writer and io.WriterAt
+type writerAt struct {
+	bytes.Buffer
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > w.Len() {
+		w.Write(make([]byte, end-w.Len()))
+	}
+	return copy(w.Bytes()[off:], p), nil
+}
+
+func TestSlab(t *testing.T) {
+	f, fd := slabFile(t)
+	temp := f.Vars.Get("temp")
+
+	t.Run("one timestep", func(t *testing.T) {
+		values, err := Slab[float32](f, temp, types.Slab{
+			Start: []int64{2, 0, 0},
+			Count: []int64{1, 2, 3},
+		}, fd)
+		require.NoError(t, err)
+		assert.Equal(t, []float32{200, 201, 202, 210, 211, 212}, values)
+	})
+
+	t.Run("strided", func(t *testing.T) {
+		values, err := Slab[float32](f, temp, types.Slab{
+			Start:  []int64{1, 1, 0},
+			Count:  []int64{2, 1, 2},
+			Stride: []int64{2, 1, 2},
+		}, fd)
+		require.NoError(t, err)
+		assert.Equal(t, []float32{110, 112, 310, 312}, values)
+	})
+
+	t.Run("index map", func(t *testing.T) {
+		values, err := Slab[float32](f, temp, types.Slab{
+			Start: []int64{0, 0, 0},
+			Count: []int64{1, 2, 3},
+			IMap:  []int64{6, 1, 2},
+		}, fd)
+		require.NoError(t, err)
+		assert.Equal(t, []float32{0, 10, 1, 11, 2, 12}, values)
+	})
+
+	t.Run("record coordinate", func(t *testing.T) {
+		values, err := Slab[float64](f, f.Vars.Get("time"), types.Slab{
+			Start: []int64{1},
+			Count: []int64{3},
+		}, fd)
+		require.NoError(t, err)
+		assert.Equal(t, []float64{1, 2, 3}, values)
+	})
+
+	t.Run("out of bounds", func(t *testing.T) {
+		_, err := Slab[float32](f, temp, types.Slab{
+			Start: []int64{4, 0, 0},
+			Count: []int64{1, 2, 3},
+		}, fd)
+		assert.EqualError(t, err, "Index 4 out of bounds for dimension `time` of variable `temp`")
+	})
+}
+
+func TestVarDataCount(t *testing.T) {
+	fd, err := os.Open("../simple.nc")
+	require.NoError(t, err)
+	defer fd.Close()
+	f, err := Header(fd)
+	require.NoError(t, err)
+
+	values, err := VarData[float32](f, f.Vars.Get("red"), fd)
+	require.NoError(t, err)
+	assert.Equal(t, 100, len(values))
+
+	_, err = VarData[int16](f, f.Vars.Get("red"), fd)
+	assert.EqualError(t, err, "Cannot read values of variable `red` of type NC_FLOAT as int16")
+}
+
+func TestVarDataAny(t *testing.T) {
+	f, fd := slabFile(t)
+
+	temp, err := VarDataAny(f, f.Vars.Get("temp"), fd)
+	require.NoError(t, err)
+	require.IsType(t, []float32{}, temp)
+	assert.Equal(t, 24, len(temp.([]float32)))
+	assert.Equal(t, float32(312), temp.([]float32)[23])
+
+	tm, err := VarDataAny(f, f.Vars.Get("time"), fd)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 1, 2, 3}, tm)
+}
+
+func TestVarDataMasked(t *testing.T) {
+	f, fd := slabFile(t)
+	temp := f.Vars.Get("temp")
+	temp.Attrs = types.Attrs{{Name: "valid_max", Val: types.Floats(200)}}.Map()
+
+	values, mask, err := SlabMasked[float32](f, temp, types.Slab{
+		Start:  []int64{1, 1, 0},
+		Count:  []int64{2, 1, 2},
+		Stride: []int64{2, 1, 2},
+	}, fd)
+	require.NoError(t, err)
+	assert.Equal(t, []float32{110, 112, 310, 312}, values)
+	assert.Equal(t, []bool{false, false, true, true}, mask)
+
+	_, mask, err = VarDataMasked[float32](f, temp, fd)
+	require.NoError(t, err)
+	assert.Equal(t, 24, len(mask))
+}
+
+func TestVarArray(t *testing.T) {
+	f, fd := slabFile(t)
+	temp := f.Vars.Get("temp")
+
+	a, err := VarArray[float32](f, temp, fd)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 2, 3}, a.Shape())
+	assert.Equal(t, []string{"time", "y", "x"}, a.Dims())
+	assert.Equal(t, float32(312), a.At(3, 1, 2))
+
+	a, err = SlabArray[float32](f, temp, types.Slab{
+		Start: []int64{0, 0, 0},
+		Count: []int64{1, 2, 3},
+		IMap:  []int64{6, 1, 2},
+	}, fd)
+	require.NoError(t, err)
+	assert.Equal(t, float32(12), a.At(0, 1, 2))
+	assert.Equal(t, []float32{0, 1, 2, 10, 11, 12}, a.Values())
+}
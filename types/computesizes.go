@@ -1,14 +1,24 @@
 package types
 
+// ComputeSizes sets Size and Offset of all variables
+// of the file, using the layout of f.Version.
+// Non record variables are placed in order right after
+// the header, followed by the first record, containing
+// values of all record variables in order.
 func (f *File) ComputeSizes() *File {
 	offset := uint64(f.ByteSize())
-	for _, it := range f.Vars.Items() {
-		name := it.K
-		v := it.V
-		v.Offset = offset
-		v.Size = v.ValueByteSize()
-		f.Vars.Set(name, v)
-		offset += uint64(v.Size)
+	for _, rec := range []bool{false, true} {
+		for _, it := range f.Vars.Items() {
+			name := it.K
+			v := it.V
+			if v.IsRecord() != rec {
+				continue
+			}
+			v.Offset = offset
+			v.Size = v.ValueByteSize()
+			f.Vars.Set(name, v)
+			offset += uint64(v.Size)
+		}
 	}
 	return f
 }
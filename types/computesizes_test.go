@@ -20,23 +20,19 @@ var file = File{
 	Dimensions: dims,
 	Attrs: Attrs{{
 		Name: "a1",
-		Val:  int16(42),
-		Type: Short,
+		Val:  Shorts(42),
 	}, {
 		Name: "a2",
-		Val:  int16(42),
-		Type: Short,
+		Val:  Shorts(42),
 	}}.Map(),
 	Vars: Vars{{
 		Name: "red",
 		Attrs: Attrs{{
 			Name: "t1",
-			Val:  int16(42),
-			Type: Short,
+			Val:  Shorts(42),
 		}, {
 			Name: "t2",
-			Val:  int16(42),
-			Type: Short,
+			Val:  Shorts(42),
 		}}.Map(),
 
 		Dimensions: []*Dimension{&dims[0], &dims[1]},
@@ -45,12 +41,10 @@ var file = File{
 		Name: "blu",
 		Attrs: Attrs{{
 			Name: "t1",
-			Val:  int16(42),
-			Type: Short,
+			Val:  Shorts(42),
 		}, {
 			Name: "t2",
-			Val:  int16(42),
-			Type: Short,
+			Val:  Shorts(42),
 		}}.Map(),
 		Dimensions: []*Dimension{&dims[0], &dims[1]},
 		Type:       Short,
@@ -62,7 +56,63 @@ func TestComputeSizes(t *testing.T) {
 	assert.Equal(t, int32(headSz), file.ByteSize())
 	file.ComputeSizes()
 	assert.Equal(t, uint64(264), file.Vars.Get("red").Offset)
-	assert.Equal(t, int32(12), file.Vars.Get("red").Size)
+	assert.Equal(t, int64(12), file.Vars.Get("red").Size)
 	assert.Equal(t, uint64(276), file.Vars.Get("blu").Offset)
-	assert.Equal(t, int32(12), file.Vars.Get("blu").Size)
+	assert.Equal(t, int64(12), file.Vars.Get("blu").Size)
+}
+
+func TestComputeSizesVersions(t *testing.T) {
+	f1 := file
+	f1.Version = CDF1
+	f1.Vars = Vars{file.Vars.Get("red"), file.Vars.Get("blu")}.Map()
+	// each variable header is 4 bytes shorter
+	assert.Equal(t, int32(264-8), f1.ByteSize())
+	f1.ComputeSizes()
+	assert.Equal(t, uint64(256), f1.Vars.Get("red").Offset)
+	assert.Equal(t, uint64(268), f1.Vars.Get("blu").Offset)
+
+	f5 := file
+	f5.Version = CDF5
+	f5.Vars = Vars{file.Vars.Get("red"), file.Vars.Get("blu")}.Map()
+	f5.ComputeSizes()
+	assert.Equal(t, uint64(f5.ByteSize()), f5.Vars.Get("red").Offset)
+	assert.Equal(t, uint64(f5.ByteSize())+12, f5.Vars.Get("blu").Offset)
+}
+
+func TestComputeSizesRecords(t *testing.T) {
+	dims := []Dimension{{Name: "time", Len: 0}, {Name: "x", Len: 3}}
+	f := File{
+		Dimensions: dims,
+		Vars: Vars{
+			{Name: "temp", Type: Short, Dimensions: []*Dimension{&dims[0], &dims[1]}},
+			{Name: "x", Type: Short, Dimensions: []*Dimension{&dims[1]}},
+			{Name: "time", Type: Double, Dimensions: []*Dimension{&dims[0]}},
+		}.Map(),
+	}
+	assert.True(t, f.Vars.Get("temp").IsRecord())
+	assert.False(t, f.Vars.Get("x").IsRecord())
+
+	f.ComputeSizes()
+	head := uint64(f.ByteSize())
+	assert.Equal(t, head, f.Vars.Get("x").Offset)
+	assert.Equal(t, int64(8), f.Vars.Get("x").Size)
+	assert.Equal(t, head+8, f.Vars.Get("temp").Offset)
+	assert.Equal(t, int64(8), f.Vars.Get("temp").Size)
+	assert.Equal(t, head+16, f.Vars.Get("time").Offset)
+	assert.Equal(t, int64(8), f.Vars.Get("time").Size)
+	assert.Equal(t, int64(16), f.RecSize())
+}
+
+func TestRecSizeSingleVar(t *testing.T) {
+	dims := []Dimension{{Name: "time", Len: 0}, {Name: "x", Len: 3}}
+	f := File{
+		Dimensions: dims,
+		Vars: Vars{
+			{Name: "temp", Type: Short, Dimensions: []*Dimension{&dims[0], &dims[1]}},
+		}.Map(),
+	}
+	f.ComputeSizes()
+	// vsize is padded, records are not
+	assert.Equal(t, int64(8), f.Vars.Get("temp").Size)
+	assert.Equal(t, int64(6), f.RecSize())
 }
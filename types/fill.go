@@ -0,0 +1,134 @@
+package types
+
+import (
+	"fmt"
+	"math"
+)
+
+// Default fill values for each type, as defined by netcdf.
+const (
+	FillByte   int8    = -127
+	FillChar   byte    = 0
+	FillShort  int16   = -32767
+	FillInt    int32   = -2147483647
+	FillFloat  float32 = 9.9692099683868690e+36
+	FillDouble float64 = 9.9692099683868690e+36
+	FillUByte  byte    = 255
+	FillUShort uint16  = 65535
+	FillUInt   uint32  = 4294967295
+	FillInt64  int64   = -9223372036854775806
+	FillUInt64 uint64  = 18446744073709551614
+)
+
+// DefaultFill returns the default fill value for type t.
+func (t Type) DefaultFill() Value {
+	switch t {
+	case Byte:
+		fill := FillByte
+		return Bytes(byte(fill))
+	case Char:
+		return Text(string([]byte{FillChar}))
+	case Short:
+		return Shorts(FillShort)
+	case Int:
+		return Ints(FillInt)
+	case Float:
+		return Floats(FillFloat)
+	case Double:
+		return Doubles(FillDouble)
+	case UByte:
+		return UBytes(FillUByte)
+	case UShort:
+		return UShorts(FillUShort)
+	case UInt:
+		return UInts(FillUInt)
+	case Int64:
+		return Int64s(FillInt64)
+	case UInt64:
+		return UInt64s(FillUInt64)
+	}
+	return Value{}
+}
+
+// FillValue returns the value used to pre-fill variable v:
+// the value of its _FillValue attribute if present,
+// or the default fill value for its type.
+// It returns an error if _FillValue does not contain
+// a single value convertible to the type of v.
+func (v Var) FillValue() (Value, error) {
+	fill, ok := v.Attr("_FillValue")
+	if !ok {
+		return v.Type.DefaultFill(), nil
+	}
+	if fill.Len() != 1 {
+		return Value{}, fmt.Errorf("_FillValue of variable `%s` must contain a single value", v.Name)
+	}
+	return fill.Convert(v.Type)
+}
+
+// MissingFunc returns a function that reports whether
+// a value of variable v is missing. A value is missing when:
+//
+// * it's equal to the fill value of the variable (see FillValue)
+// * it's equal to one of the values of the missing_value attribute
+// * it's outside of the range given by the valid_range attribute,
+// or by valid_min and valid_max attributes.
+//
+// NaN values are missing if the fill value or
+// one of the missing values are NaN.
+func (v Var) MissingFunc() func(x float64) bool {
+	var missing []float64
+	if fill, err := v.FillValue(); err == nil {
+		missing = append(missing, fill.Float64s()...)
+	}
+	if mv, ok := v.Attr("missing_value"); ok {
+		missing = append(missing, mv.Float64s()...)
+	}
+
+	min, max := math.Inf(-1), math.Inf(1)
+	if vr, ok := v.Attr("valid_range"); ok && vr.Len() == 2 && vr.Type() != Char {
+		min, max = vr.Float64s()[0], vr.Float64s()[1]
+	}
+	if vmin, ok := v.Attr("valid_min"); ok && vmin.Len() == 1 && vmin.Type() != Char {
+		min = vmin.Float64s()[0]
+	}
+	if vmax, ok := v.Attr("valid_max"); ok && vmax.Len() == 1 && vmax.Type() != Char {
+		max = vmax.Float64s()[0]
+	}
+
+	missingNaN := false
+	for _, m := range missing {
+		missingNaN = missingNaN || math.IsNaN(m)
+	}
+
+	return func(x float64) bool {
+		if math.IsNaN(x) {
+			return missingNaN
+		}
+		if x < min || x > max {
+			return true
+		}
+		for _, m := range missing {
+			if x == m {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Mask returns, for each value in data read from variable v,
+// whether it's missing, using the rules of Var.MissingFunc.
+func Mask[T BaseType](v Var, data []T) []bool {
+	missing := v.MissingFunc()
+	mask := make([]bool, len(data))
+	for i, x := range data {
+		f := float64(x)
+		if b, ok := any(x).(byte); ok && v.Type == Byte {
+			// NC_BYTE values are signed
+			f = float64(int8(b))
+		}
+		mask[i] = missing(f)
+	}
+	return mask
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultFill(t *testing.T) {
+	assert.Equal(t, []float64{-127}, Byte.DefaultFill().Float64s())
+	assert.Equal(t, "\x00", Char.DefaultFill().String())
+	assert.Equal(t, Shorts(-32767), Short.DefaultFill())
+	assert.Equal(t, Ints(-2147483647), Int.DefaultFill())
+	assert.Equal(t, Floats(9.9692099683868690e+36), Float.DefaultFill())
+	assert.Equal(t, Doubles(9.9692099683868690e+36), Double.DefaultFill())
+	assert.Equal(t, UBytes(255), UByte.DefaultFill())
+	assert.Equal(t, UShorts(65535), UShort.DefaultFill())
+	assert.Equal(t, UInts(4294967295), UInt.DefaultFill())
+	assert.Equal(t, Int64s(-9223372036854775806), Int64.DefaultFill())
+	assert.Equal(t, UInt64s(18446744073709551614), UInt64.DefaultFill())
+}
+
+func TestFillValue(t *testing.T) {
+	v := Var{Name: "temp", Type: Short}
+	fill, err := v.FillValue()
+	require.NoError(t, err)
+	assert.Equal(t, Shorts(-32767), fill)
+
+	v.Attrs = Attrs{{Name: "_FillValue", Val: Ints(-1)}}.Map()
+	fill, err = v.FillValue()
+	require.NoError(t, err)
+	assert.Equal(t, Shorts(-1), fill)
+
+	v.Attrs = Attrs{{Name: "_FillValue", Val: Shorts(1, 2)}}.Map()
+	_, err = v.FillValue()
+	assert.EqualError(t, err, "_FillValue of variable `temp` must contain a single value")
+}
+
+func TestMask(t *testing.T) {
+	t.Run("default fill", func(t *testing.T) {
+		v := Var{Name: "temp", Type: Short}
+		assert.Equal(t, []bool{false, true, false}, Mask(v, []int16{1, FillShort, 3}))
+	})
+
+	t.Run("signed bytes", func(t *testing.T) {
+		v := Var{Name: "flag", Type: Byte}
+		assert.Equal(t, []bool{false, true}, Mask(v, []byte{1, 0x81}))
+	})
+
+	t.Run("fill and missing values", func(t *testing.T) {
+		v := Var{Name: "temp", Type: Float, Attrs: Attrs{
+			{Name: "_FillValue", Val: Floats(-999)},
+			{Name: "missing_value", Val: Floats(-1, -2)},
+		}.Map()}
+		assert.Equal(t,
+			[]bool{true, true, true, false, false},
+			Mask(v, []float32{-999, -1, -2, 0, FillFloat}),
+		)
+	})
+
+	t.Run("valid range", func(t *testing.T) {
+		v := Var{Name: "temp", Type: Int, Attrs: Attrs{
+			{Name: "valid_range", Val: Ints(0, 10)},
+		}.Map()}
+		assert.Equal(t, []bool{true, false, false, true}, Mask(v, []int32{-1, 0, 10, 11}))
+
+		v.Attrs = Attrs{{Name: "valid_min", Val: Ints(5)}}.Map()
+		assert.Equal(t, []bool{true, false, false}, Mask(v, []int32{4, 5, 100}))
+
+		v.Attrs = Attrs{{Name: "valid_max", Val: Ints(5)}}.Map()
+		assert.Equal(t, []bool{false, false, true}, Mask(v, []int32{-100, 5, 6}))
+	})
+
+	t.Run("NaN", func(t *testing.T) {
+		v := Var{Name: "temp", Type: Double}
+		assert.Equal(t, []bool{false}, Mask(v, []float64{math.NaN()}))
+
+		v.Attrs = Attrs{{Name: "_FillValue", Val: Doubles(math.NaN())}}.Map()
+		assert.Equal(t, []bool{true, false}, Mask(v, []float64{math.NaN(), 1}))
+	})
+}
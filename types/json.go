@@ -1,6 +1,9 @@
 package types
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // MarshalJSON ...
 func (t Type) MarshalJSON() ([]byte, error) {
@@ -11,3 +14,16 @@ func (t Type) MarshalJSON() ([]byte, error) {
 func (v Version) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf(`%d`, v[3])), nil
 }
+
+// MarshalJSON ...
+func (v Value) MarshalJSON() ([]byte, error) {
+	switch v.typ {
+	case Char:
+		return json.Marshal(v.String())
+	case Byte, UByte, Unknown:
+		// a []byte would be marshalled as a base64 string
+		return json.Marshal(v.Float64s())
+	}
+	// 64-bit integers don't fit in a float64
+	return json.Marshal(v.data)
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueMarshalJSON(t *testing.T) {
+	cases := map[string]Value{
+		`"a\"b"`:                 Text(`a"b`),
+		`[1,-1]`:                 Bytes(1, 255),
+		`[255]`:                  UBytes(255),
+		`[-3,4]`:                 Shorts(-3, 4),
+		`[0.1,2]`:                Floats(0.1, 2),
+		`[9223372036854775807]`:  Int64s(math.MaxInt64),
+		`[18446744073709551615]`: UInt64s(math.MaxUint64),
+		`null`:                   {},
+	}
+	for expected, val := range cases {
+		out, err := json.Marshal(val)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(out))
+	}
+}
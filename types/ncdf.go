@@ -11,6 +11,15 @@ import (
 // the 4 byte.
 type Version [4]byte
 
+var (
+	// CDF1 is the version of classic format files
+	CDF1 = Version{'C', 'D', 'F', 1}
+	// CDF2 is the version of 64-bit offset format files
+	CDF2 = Version{'C', 'D', 'F', 2}
+	// CDF5 is the version of 64-bit data format files
+	CDF5 = Version{'C', 'D', 'F', 5}
+)
+
 // File represent an open netcdf file
 // It has an os.File field containing
 // the fd of file being read.
@@ -18,13 +27,20 @@ type File struct {
 	//fd      io.ReadSeekCloser
 	//Count   uint64
 	Version Version
-	NumRecs int32
+	NumRecs int64
 	//Dimensions    map[string]Dimension
 	Dimensions []Dimension
 	Attrs      ordmap.OrderedMap[Attr, string]
 	Vars       ordmap.OrderedMap[Var, string]
 }
 
+// Streaming is the value of NumRecs for files
+// written in streaming mode, whose header has
+// numrecs = \xFF \xFF \xFF \xFF. The actual
+// number of records must be derived from the size
+// of the file.
+const Streaming int64 = -1
+
 // Tag ...
 type Tag byte
 
@@ -45,25 +61,76 @@ type Var struct {
 	Attrs      ordmap.OrderedMap[Attr, string]
 	Name       string
 	Type       Type
-	Size       int32
+	Size       int64
 	Offset     uint64
+	// Data optionally holds values of the variable in
+	// memory, e.g. when parsed from a CDL data section.
+	// Values of record variables span all records.
+	Data Value `json:",omitempty"`
 }
 
 // Attr ...
 type Attr struct {
 	Name string
-	Val  interface{}
-	Type Type
+	Val  Value
 	//file *File
 }
 
+// Type returns the type of the values of the attribute.
+func (a Attr) Type() Type {
+	return a.Val.Type()
+}
+
+// Attr returns the values of the global attribute
+// called name, and whether it exists.
+func (f File) Attr(name string) (Value, bool) {
+	if !f.Attrs.Has(name) {
+		return Value{}, false
+	}
+	return f.Attrs.Get(name).Val, true
+}
+
+// Attr returns the values of the attribute of v
+// called name, and whether it exists.
+func (v Var) Attr(name string) (Value, bool) {
+	if !v.Attrs.Has(name) {
+		return Value{}, false
+	}
+	return v.Attrs.Get(name).Val, true
+}
+
 // Dimension ...
 type Dimension struct {
 	Name string
-	Len  int32
+	Len  int64
 	//file *File
 }
 
+// IsUnlimited returns whether d is the
+// unlimited (record) dimension.
+func (d Dimension) IsUnlimited() bool {
+	return d.Len == 0
+}
+
+// IsRecord returns whether v is a record variable,
+// that is whether its first dimension is the
+// unlimited one.
+func (v Var) IsRecord() bool {
+	return len(v.Dimensions) > 0 && v.Dimensions[0].IsUnlimited()
+}
+
+// RecordVars returns all record variables of the file,
+// in the same order as they are defined.
+func (f File) RecordVars() []Var {
+	var res []Var
+	for _, v := range f.Vars.Values() {
+		if v.IsRecord() {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
 // Check ...
 func (v Version) Check() error {
 	if v[0] != 'C' ||
@@ -71,12 +138,54 @@ func (v Version) Check() error {
 		v[2] != 'F' {
 		return fmt.Errorf("Invalid magic string %v", v[0:3])
 	}
-	if v[3] != 1 && v[3] != 2 {
+	if v[3] != 1 && v[3] != 2 && v[3] != 5 {
 		return fmt.Errorf("Invalid version %d", v[3])
 	}
 	return nil
 }
 
+// CountSize returns the size in bytes of the
+// non negative counts stored in the header
+// (list lengths, dimension lengths, variables sizes
+// and number of records): 64 bits for CDF-5 files,
+// 32 bits otherwise.
+func (v Version) CountSize() int {
+	if v[3] == 5 {
+		return 8
+	}
+	return 4
+}
+
+// Supports returns whether values of type t
+// can be stored in files of this version.
+// Types added by CDF-5 are only supported
+// in CDF-5 files.
+func (v Version) Supports(t Type) bool {
+	if v[3] == 5 {
+		return t >= Byte && t <= UInt64
+	}
+	return t >= Byte && t <= Double
+}
+
+// OffsetSize returns the size in bytes of the
+// begin offset of variables: 32 bits for
+// CDF-1 files, 64 bits otherwise.
+func (v Version) OffsetSize() int {
+	if v[3] == 1 {
+		return 4
+	}
+	return 8
+}
+
+// OrDefault returns v, or CDF2 if v is
+// the zero value.
+func (v Version) OrDefault() Version {
+	if v == (Version{}) {
+		return CDF2
+	}
+	return v
+}
+
 func (t Tag) String() string {
 	switch t {
 	case ZeroTag:
@@ -0,0 +1,185 @@
+package types
+
+import (
+	"fmt"
+	"math"
+)
+
+// Floating is the constraint satisfied
+// by the go types of unpacked values.
+type Floating interface {
+	float32 | float64
+}
+
+// Packing describes how values of a variable are packed,
+// following the CF conventions: an unpacked value is
+// computed as packed * ScaleFactor + AddOffset.
+// Type is the type of unpacked values, either
+// NC_FLOAT or NC_DOUBLE.
+type Packing struct {
+	ScaleFactor float64
+	AddOffset   float64
+	Type        Type
+}
+
+// Packing returns the packing of variable v, read from its
+// scale_factor and add_offset attributes, and whether
+// at least one of them is present.
+//
+// The type of unpacked values is the type of the attributes
+// when they are NC_FLOAT or NC_DOUBLE (NC_DOUBLE if they differ).
+// Otherwise it is NC_DOUBLE for variables of type NC_DOUBLE and of
+// 32 or 64 bits integer types, and NC_FLOAT for the other types.
+func (v Var) Packing() (Packing, bool) {
+	p := Packing{ScaleFactor: 1, Type: Float}
+	switch v.Type {
+	case Double, Int, UInt, Int64, UInt64:
+		p.Type = Double
+	}
+
+	packed := false
+	attrType := Unknown
+	read := func(name string, dest *float64) {
+		val, ok := v.Attr(name)
+		if !ok || val.Len() != 1 || val.Type() == Char {
+			return
+		}
+		packed = true
+		*dest = val.Float64s()[0]
+		if val.Type() == Double || (val.Type() == Float && attrType != Double) {
+			attrType = val.Type()
+		}
+	}
+	read("scale_factor", &p.ScaleFactor)
+	read("add_offset", &p.AddOffset)
+	if attrType != Unknown {
+		p.Type = attrType
+	}
+	return p, packed
+}
+
+// Attrs returns the scale_factor and add_offset
+// attributes describing p.
+func (p Packing) Attrs() Attrs {
+	val := func(x float64) Value {
+		if p.Type == Double {
+			return Doubles(x)
+		}
+		return Floats(float32(x))
+	}
+	return Attrs{
+		{Name: "scale_factor", Val: val(p.ScaleFactor)},
+		{Name: "add_offset", Val: val(p.AddOffset)},
+	}
+}
+
+// packedRange returns the range of values of type t
+// used to store packed values. The value used as default
+// fill for t is excluded from the range.
+func packedRange(t Type) (min, max float64, err error) {
+	switch t {
+	case Byte:
+		return math.MinInt8 + 2, math.MaxInt8, nil
+	case Short:
+		return math.MinInt16 + 2, math.MaxInt16, nil
+	case Int:
+		return math.MinInt32 + 2, math.MaxInt32, nil
+	case UByte:
+		return 0, math.MaxUint8 - 1, nil
+	case UShort:
+		return 0, math.MaxUint16 - 1, nil
+	case UInt:
+		return 0, math.MaxUint32 - 1, nil
+	}
+	return 0, 0, fmt.Errorf("Cannot pack values into type %s", t)
+}
+
+// NewPacking computes the packing needed to store data in
+// values of type packed, using all the range of the type but
+// its default fill value. The type of unpacked values is
+// FromValueType[T](). NaN values are ignored.
+func NewPacking[T Floating](packed Type, data []T) (Packing, error) {
+	pmin, pmax, err := packedRange(packed)
+	if err != nil {
+		return Packing{}, err
+	}
+
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, x := range data {
+		f := float64(x)
+		if math.IsNaN(f) {
+			continue
+		}
+		min = math.Min(min, f)
+		max = math.Max(max, f)
+	}
+	if math.IsInf(min, 0) || math.IsInf(max, 0) {
+		// no values, or infinite ones
+		return Packing{ScaleFactor: 1, Type: FromValueType[T]()}, nil
+	}
+
+	p := Packing{ScaleFactor: 1, Type: FromValueType[T]()}
+	if max > min {
+		p.ScaleFactor = (max - min) / (pmax - pmin)
+	}
+	p.AddOffset = min - pmin*p.ScaleFactor
+	return p, nil
+}
+
+// Unpack returns the unpacked values of variable v,
+// following its packing (see Var.Packing).
+// Fill and missing values (see Var.MissingFunc) are
+// masked before unpacking, and returned as NaN.
+// It returns an error if T doesn't match the
+// type of unpacked values.
+func Unpack[T Floating](v Var, packed Value) ([]T, error) {
+	p, _ := v.Packing()
+	if FromValueType[T]() != p.Type {
+		var empty T
+		return nil, fmt.Errorf("Values of variable `%s` unpack to %s, cannot unpack them as %T", v.Name, p.Type, empty)
+	}
+
+	missing := v.MissingFunc()
+	values := packed.Float64s()
+	res := make([]T, len(values))
+	for i, x := range values {
+		if missing(x) {
+			res[i] = T(math.NaN())
+			continue
+		}
+		res[i] = T(x*p.ScaleFactor + p.AddOffset)
+	}
+	return res, nil
+}
+
+// Pack returns data packed in values of the type of
+// variable v, following its packing (see Var.Packing).
+// NaN values are packed as the fill value of v.
+// It returns an error if some value is out of the
+// range of the type of v.
+func Pack[T Floating](v Var, data []T) (Value, error) {
+	if v.Type == Char {
+		return Value{}, fmt.Errorf("Cannot pack values into type %s", v.Type)
+	}
+	p, _ := v.Packing()
+	fill, err := v.FillValue()
+	if err != nil {
+		return Value{}, err
+	}
+	fillValue := fill.Float64s()[0]
+	round := v.Type != Float && v.Type != Double
+
+	values := make([]float64, len(data))
+	for i, x := range data {
+		f := float64(x)
+		if math.IsNaN(f) {
+			values[i] = fillValue
+			continue
+		}
+		values[i] = (f - p.AddOffset) / p.ScaleFactor
+		if round {
+			values[i] = math.Round(values[i])
+		}
+	}
+	return Doubles(values...).Convert(v.Type)
+}
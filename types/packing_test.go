@@ -0,0 +1,73 @@
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVarPacking(t *testing.T) {
+	v := Var{Name: "temp", Type: Short}
+	p, ok := v.Packing()
+	assert.False(t, ok)
+	assert.Equal(t, Packing{ScaleFactor: 1, Type: Float}, p)
+
+	v.Attrs = Attrs{{Name: "scale_factor", Val: Floats(0.5)}}.Map()
+	p, ok = v.Packing()
+	assert.True(t, ok)
+	assert.Equal(t, Packing{ScaleFactor: 0.5, Type: Float}, p)
+
+	v.Attrs = Attrs{
+		{Name: "scale_factor", Val: Floats(0.5)},
+		{Name: "add_offset", Val: Doubles(273.15)},
+	}.Map()
+	p, _ = v.Packing()
+	assert.Equal(t, Packing{ScaleFactor: 0.5, AddOffset: 273.15, Type: Double}, p)
+
+	v = Var{Name: "count", Type: Int, Attrs: Attrs{{Name: "add_offset", Val: Ints(10)}}.Map()}
+	p, ok = v.Packing()
+	assert.True(t, ok)
+	assert.Equal(t, Packing{ScaleFactor: 1, AddOffset: 10, Type: Double}, p)
+}
+
+func TestNewPacking(t *testing.T) {
+	p, err := NewPacking(Short, []float64{-10, math.NaN(), 10})
+	require.NoError(t, err)
+	assert.Equal(t, Double, p.Type)
+	assert.InDelta(t, 20.0/65533, p.ScaleFactor, 1e-12)
+	assert.Equal(t, Doubles(p.ScaleFactor), p.Attrs()[0].Val)
+
+	p, err = NewPacking(UByte, []float32{5, 5})
+	require.NoError(t, err)
+	assert.Equal(t, Packing{ScaleFactor: 1, AddOffset: 5, Type: Float}, p)
+
+	_, err = NewPacking(Double, []float32{5})
+	assert.EqualError(t, err, "Cannot pack values into type NC_DOUBLE")
+}
+
+func TestPackUnpack(t *testing.T) {
+	data := []float32{-10, 0, float32(math.NaN()), 10}
+	p, err := NewPacking(Short, data)
+	require.NoError(t, err)
+	v := Var{Name: "temp", Type: Short, Attrs: p.Attrs().Map()}
+
+	packed, err := Pack(v, data)
+	require.NoError(t, err)
+	assert.Equal(t, Short, packed.Type())
+	assert.Equal(t, []float64{-32766, 1, float64(FillShort), 32767}, packed.Float64s())
+
+	unpacked, err := Unpack[float32](v, packed)
+	require.NoError(t, err)
+	assert.InDelta(t, -10, unpacked[0], 1e-3)
+	assert.InDelta(t, 0, unpacked[1], 1e-3)
+	assert.True(t, math.IsNaN(float64(unpacked[2])))
+	assert.InDelta(t, 10, unpacked[3], 1e-3)
+
+	_, err = Unpack[float64](v, packed)
+	assert.EqualError(t, err, "Values of variable `temp` unpack to NC_FLOAT, cannot unpack them as float64")
+
+	_, err = Pack(v, []float32{20})
+	assert.EqualError(t, err, "Value 65534 out of range of type NC_SHORT")
+}
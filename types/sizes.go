@@ -1,77 +1,146 @@
 package types
 
+// ByteSize returns the size in bytes of the header
+// of the file, using the layout of f.Version.
+// An empty version uses the layout of CDF-2 files.
 func (f File) ByteSize() int32 {
+	cs := int32(f.Version.CountSize())
+
 	var szAttrs int
-	szAttrs += 8 // len+tag
+	szAttrs += 4 + int(cs) // len+tag
 	for _, it := range f.Attrs.Values() {
-		szAttrs += int(it.ByteSize())
+		szAttrs += int(it.ByteSizeFor(f.Version))
 	}
 
-	szAttrs += 8 // len+tag
+	szAttrs += 4 + int(cs) // len+tag
 	for _, it := range f.Dimensions {
-		szAttrs += int(it.ByteSize())
+		szAttrs += int(it.ByteSizeFor(f.Version))
 	}
 
-	szAttrs += 8 // len+tag
+	szAttrs += 4 + int(cs) // len+tag
 	for _, it := range f.Vars.Values() {
-		szAttrs += int(it.ByteSize())
+		szAttrs += int(it.ByteSizeFor(f.Version))
 	}
 
 	return int32(
 		szAttrs +
-			4 + // numrecs
+			int(cs) + // numrecs
 			4 + // magic & Version
 			0)
 
 }
+
+// ByteSize returns the size in bytes of the variable
+// header, using the layout of CDF-2 files.
 func (v Var) ByteSize() int32 {
+	return v.ByteSizeFor(Version{})
+}
+
+// ByteSizeFor returns the size in bytes of the variable
+// header, using the layout of files of version ver.
+func (v Var) ByteSizeFor(ver Version) int32 {
+	cs := int32(ver.CountSize())
+
 	var szAttrs int32
-	szAttrs += 4 + 4 // len+attr tag
+	szAttrs += 4 + cs // len+attr tag
 	for _, a := range v.Attrs.Values() {
-		szAttrs += a.ByteSize()
+		szAttrs += a.ByteSizeFor(ver)
 	}
 
-	return 4 + int32(len(v.Dimensions))*4 + // Dimensions
+	return cs + int32(len(v.Dimensions))*cs + // Dimensions
 		szAttrs +
-		stringByteSize(v.Name) + // Name string
-		4 + //Size
-		8 + // Offset
+		stringByteSize(v.Name, ver) + // Name string
+		cs + //Size
+		int32(ver.OffsetSize()) + // Offset
 		4 // Type
 
 }
 
-// TODO: add support for array values
+// ByteSize returns the size in bytes of the attribute
+// header, using the layout of CDF-2 files.
 func (a Attr) ByteSize() int32 {
+	return a.ByteSizeFor(Version{})
+}
+
+// ByteSizeFor returns the size in bytes of the attribute
+// header, using the layout of files of version ver.
+func (a Attr) ByteSizeFor(ver Version) int32 {
 	// pad value
 	sz := a.ValueByteSize()
 
-	return stringByteSize(a.Name) + // Name string
+	return stringByteSize(a.Name, ver) + // Name string
 		4 + // Type
-		4 + // len
+		int32(ver.CountSize()) + // len
 		sz
 }
 
+// ValueByteSize returns the size in bytes of the values
+// of the attribute, aligned to 32 bits.
 func (a Attr) ValueByteSize() int32 {
-	return int32(a.Type.ArraySize(1))
+	n := a.Val.Len()
+	if n == 0 {
+		return 0
+	}
+	return int32(a.Type().ArraySize(n))
 }
 
-func (v Var) ValueByteSize() int32 {
-	var len = 1
+// ValueByteSize returns the size in bytes of the values
+// of the variable, aligned to 32 bits.
+// For record variables, it is the size of the values
+// stored in a single record.
+func (v Var) ValueByteSize() int64 {
+	return int64(v.Type.ArraySize(int(v.RecordLen())))
+}
+
+// RecordLen returns the number of values of the variable.
+// For record variables, it is the number of values
+// stored in a single record.
+func (v Var) RecordLen() int64 {
+	var len int64 = 1
 
 	for _, d := range v.Dimensions {
-		len *= int(d.Len)
+		if d.IsUnlimited() {
+			continue
+		}
+		len *= d.Len
 	}
 
-	return int32(v.Type.ArraySize(len))
+	return len
+}
+
+// RecSize returns the size in bytes of a record,
+// that is the sum of the sizes of all record variables.
+// When the file contains a single record variable,
+// records are not padded, so this is the unaligned
+// size of its values.
+func (f File) RecSize() int64 {
+	recVars := f.RecordVars()
+	if len(recVars) == 1 {
+		v := recVars[0]
+		return v.RecordLen() * int64(v.Type.ScalarSize())
+	}
 
+	var sz int64
+	for _, v := range recVars {
+		sz += v.Size
+	}
+	return sz
 }
 
-func stringByteSize(val string) int32 {
-	return int32(4 + Byte.ArraySize(len(val)))
+func stringByteSize(val string, ver Version) int32 {
+	return int32(ver.CountSize() + Byte.ArraySize(len(val)))
 }
 
+// ByteSize returns the size in bytes of the dimension
+// header, using the layout of CDF-2 files.
 func (d Dimension) ByteSize() int32 {
-	return stringByteSize(d.Name) + // Name string
-		4 // Len
+	return d.ByteSizeFor(Version{})
+}
+
+// ByteSizeFor returns the size in bytes of the dimension
+// header, using the layout of files of version ver.
+func (d Dimension) ByteSizeFor(ver Version) int32 {
+	return stringByteSize(d.Name, ver) + // Name string
+		int32(ver.CountSize()) // Len
 
 }
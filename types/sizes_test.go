@@ -12,19 +12,16 @@ var d = Dimension{
 }
 var a = Attr{
 	Name: "test",
-	Val:  int16(42),
-	Type: Short,
+	Val:  Shorts(42),
 }
 var v = Var{
 	Name: "test",
 	Attrs: Attrs{{
 		Name: "tst1",
-		Val:  int16(42),
-		Type: Short,
+		Val:  Shorts(42),
 	}, {
 		Name: "tst2",
-		Val:  int16(42),
-		Type: Short,
+		Val:  Shorts(42),
 	}}.Map(),
 	Dimensions: []*Dimension{nil, nil, nil},
 	Type:       Short,
@@ -38,23 +35,19 @@ var f = File{
 	Dimensions: []Dimension{d, d},
 	Attrs: Attrs{{
 		Name: "a1",
-		Val:  int16(42),
-		Type: Short,
+		Val:  Shorts(42),
 	}, {
 		Name: "a2",
-		Val:  int16(42),
-		Type: Short,
+		Val:  Shorts(42),
 	}}.Map(),
 	Vars: Vars{{
 		Name: "var1",
 		Attrs: Attrs{{
 			Name: "tst1",
-			Val:  int16(42),
-			Type: Short,
+			Val:  Shorts(42),
 		}, {
 			Name: "tst2",
-			Val:  int16(42),
-			Type: Short,
+			Val:  Shorts(42),
 		}}.Map(),
 		Dimensions: []*Dimension{nil, nil, nil},
 		Type:       Short,
@@ -64,12 +57,10 @@ var f = File{
 		Name: "var2",
 		Attrs: Attrs{{
 			Name: "tst1",
-			Val:  int16(42),
-			Type: Short,
+			Val:  Shorts(42),
 		}, {
 			Name: "tst2",
-			Val:  int16(42),
-			Type: Short,
+			Val:  Shorts(42),
 		}}.Map(),
 		Dimensions: []*Dimension{nil, nil, nil},
 		Type:       Short,
@@ -99,3 +90,35 @@ func TestVarSize(t *testing.T) {
 
 	assert.Equal(t, int32(88), v.ByteSize())
 }
+
+func TestCDF5Sizes(t *testing.T) {
+	assert.Equal(t, int32(20), d.ByteSizeFor(CDF5))
+	assert.Equal(t, int32(28), a.ByteSizeFor(CDF5))
+	// name 12 + dims 8+24 + attrs 12+28+28 + type 4 + size 8 + offset 8
+	assert.Equal(t, int32(132), v.ByteSizeFor(CDF5))
+
+	f5 := f
+	f5.Version = CDF5
+	var expected = 20 + 20 + 4 + 8 + //dims
+		28 + 28 + 4 + 8 + //attrs
+		132 + 132 + 4 + 8 + //vars
+		4 + 8 // magic + recs
+	assert.Equal(t, int32(expected), f5.ByteSize())
+}
+
+func TestSupports(t *testing.T) {
+	assert.True(t, CDF2.Supports(Double))
+	assert.False(t, CDF2.Supports(UByte))
+	assert.False(t, CDF1.Supports(Int64))
+	assert.True(t, CDF5.Supports(UInt64))
+	assert.False(t, CDF5.Supports(Unknown))
+}
+
+func TestAttrArraySize(t *testing.T) {
+	// name 8 + type 4 + len 4 + values
+	assert.Equal(t, int32(16+16), Attr{Name: "test", Val: Doubles(0, 100)}.ByteSize())
+	assert.Equal(t, int32(16+8), Attr{Name: "test", Val: Text("hello")}.ByteSize())
+	assert.Equal(t, int32(16+8), Attr{Name: "test", Val: Shorts(1, 2, 3)}.ByteSize())
+	assert.Equal(t, int32(16), Attr{Name: "test", Val: Text("")}.ByteSize())
+	assert.Equal(t, int32(0), Attr{}.ValueByteSize())
+}
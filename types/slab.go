@@ -0,0 +1,236 @@
+package types
+
+import "fmt"
+
+// Slab describes a subset of the values of a variable.
+// For each dimension of the variable, values are selected
+// starting at index Start, taking Count values separated
+// by Stride indexes.
+// A nil Stride selects contiguous values along all dimensions.
+//
+// IMap optionally specifies, for each dimension, the distance
+// between two consecutive values along that dimension in the
+// memory array the values are read into or written from.
+// A nil IMap means the memory array has shape Count,
+// in row-major order.
+type Slab struct {
+	Start  []int64
+	Count  []int64
+	Stride []int64
+	IMap   []int64
+}
+
+// Run is a sequence of values of a variable,
+// contiguous in the file, selected by a Slab.
+type Run struct {
+	// Offset of the first value in the file
+	Offset int64
+	// Len is the number of values in the run
+	Len int64
+	// Index of the first value in the memory array
+	Index int64
+	// Step is the distance between consecutive values of
+	// the run in the memory array
+	Step int64
+}
+
+// WholeSlab returns a Slab selecting all values
+// of variable v of file f.
+func (f File) WholeSlab(v Var) Slab {
+	s := Slab{
+		Start: make([]int64, len(v.Dimensions)),
+		Count: make([]int64, len(v.Dimensions)),
+	}
+	for i := range v.Dimensions {
+		s.Count[i] = f.dimLen(v, i)
+	}
+	return s
+}
+
+// Len returns the number of values selected by the slab.
+func (s Slab) Len() int64 {
+	var n int64 = 1
+	for _, c := range s.Count {
+		n *= c
+	}
+	return n
+}
+
+// MemLen returns the number of elements of the memory
+// array needed to contain values selected by the slab.
+func (s Slab) MemLen() int64 {
+	if s.IMap == nil {
+		return s.Len()
+	}
+	if s.Len() == 0 {
+		return 0
+	}
+	var last int64
+	for i, c := range s.Count {
+		last += (c - 1) * s.IMap[i]
+	}
+	return last + 1
+}
+
+func (s Slab) stride(i int) int64 {
+	if s.Stride == nil {
+		return 1
+	}
+	return s.Stride[i]
+}
+
+// imap returns the IMap of the slab,
+// computing the default one when s.IMap is nil.
+func (s Slab) imap() []int64 {
+	if s.IMap != nil {
+		return s.IMap
+	}
+	res := make([]int64, len(s.Count))
+	var step int64 = 1
+	for i := len(s.Count) - 1; i >= 0; i-- {
+		res[i] = step
+		step *= s.Count[i]
+	}
+	return res
+}
+
+// dimLen returns the length of dimension i of variable v,
+// using f.NumRecs as the length of the unlimited dimension.
+func (f File) dimLen(v Var, i int) int64 {
+	if v.Dimensions[i].IsUnlimited() {
+		return f.NumRecs
+	}
+	return v.Dimensions[i].Len
+}
+
+// CheckSlab returns an error if s is not a valid
+// selection of values of variable v.
+// When growRecords is true, the slab can select
+// records beyond f.NumRecs, as it happens when
+// writing new records.
+func (f File) CheckSlab(v Var, s Slab, growRecords bool) error {
+	n := len(v.Dimensions)
+	if len(s.Start) != n || len(s.Count) != n {
+		return fmt.Errorf("Slab for variable `%s` must have %d start and count values", v.Name, n)
+	}
+	if s.Stride != nil && len(s.Stride) != n {
+		return fmt.Errorf("Slab for variable `%s` must have %d stride values", v.Name, n)
+	}
+	if s.IMap != nil && len(s.IMap) != n {
+		return fmt.Errorf("Slab for variable `%s` must have %d imap values", v.Name, n)
+	}
+	for i, d := range v.Dimensions {
+		if s.Start[i] < 0 || s.Count[i] < 0 {
+			return fmt.Errorf("Negative start or count for dimension `%s` of variable `%s`", d.Name, v.Name)
+		}
+		if s.stride(i) < 1 {
+			return fmt.Errorf("Stride for dimension `%s` of variable `%s` must be positive", d.Name, v.Name)
+		}
+		if s.IMap != nil && s.IMap[i] < 0 {
+			return fmt.Errorf("Negative imap for dimension `%s` of variable `%s`", d.Name, v.Name)
+		}
+		if growRecords && d.IsUnlimited() {
+			continue
+		}
+		if s.Count[i] == 0 {
+			if s.Start[i] > f.dimLen(v, i) {
+				return fmt.Errorf("Start %d out of bounds for dimension `%s` of variable `%s`", s.Start[i], d.Name, v.Name)
+			}
+			continue
+		}
+		last := s.Start[i] + (s.Count[i]-1)*s.stride(i)
+		if last >= f.dimLen(v, i) {
+			return fmt.Errorf("Index %d out of bounds for dimension `%s` of variable `%s`", last, d.Name, v.Name)
+		}
+	}
+	return nil
+}
+
+// SlabRuns calls fn for each run of values of variable v
+// selected by s, in the order they are stored in the file.
+// Values contiguous in the file are grouped in the same run
+// when possible. It stops and returns the first error
+// returned by fn.
+// The slab is expected to be valid, see CheckSlab.
+func (f File) SlabRuns(v Var, s Slab, fn func(r Run) error) error {
+	if s.Len() == 0 {
+		return nil
+	}
+	n := len(v.Dimensions)
+	scalar := int64(v.Type.ScalarSize())
+	if n == 0 {
+		return fn(Run{Offset: int64(v.Offset), Len: 1, Index: 0, Step: 1})
+	}
+
+	// file distance in bytes between consecutive
+	// indexes along each dimension
+	fileStep := make([]int64, n)
+	step := scalar
+	for i := n - 1; i >= 0; i-- {
+		if v.Dimensions[i].IsUnlimited() {
+			fileStep[i] = f.RecSize()
+			continue
+		}
+		fileStep[i] = step
+		step *= v.Dimensions[i].Len
+	}
+
+	imap := s.imap()
+
+	// inner is the first of the trailing dimensions
+	// that are read as a single run.
+	inner := n - 1
+	runLen := s.Count[inner]
+	if s.stride(inner) != 1 || v.Dimensions[inner].IsUnlimited() {
+		// values are not contiguous in the file
+		runLen = 1
+	} else if s.IMap == nil {
+		for inner > 0 {
+			d := v.Dimensions[inner]
+			whole := !d.IsUnlimited() && s.Start[inner] == 0 && s.Count[inner] == d.Len
+			prev := v.Dimensions[inner-1]
+			if !whole || prev.IsUnlimited() || s.stride(inner-1) != 1 {
+				break
+			}
+			inner--
+			runLen *= s.Count[inner]
+		}
+	}
+
+	// idx holds the position, relative to the slab, of
+	// the current run along the dimensions before inner.
+	// When the run is a single value, inner dimension
+	// is iterated too.
+	outer := inner
+	if runLen == 1 && s.Count[inner] > 1 {
+		outer = inner + 1
+	}
+	idx := make([]int64, outer)
+	for {
+		r := Run{Offset: int64(v.Offset), Len: runLen, Step: imap[n-1]}
+		for i := 0; i < n; i++ {
+			pos := s.Start[i]
+			if i < outer {
+				pos += idx[i] * s.stride(i)
+				r.Index += idx[i] * imap[i]
+			}
+			r.Offset += pos * fileStep[i]
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+
+		// increment idx
+		i := outer - 1
+		for ; i >= 0; i-- {
+			idx[i]++
+			if idx[i] < s.Count[i] {
+				break
+			}
+			idx[i] = 0
+		}
+		if i < 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func slabFile() File {
+	dims := []Dimension{{Name: "time", Len: 0}, {Name: "y", Len: 2}, {Name: "x", Len: 3}}
+	f := File{
+		NumRecs:    4,
+		Dimensions: dims,
+		Vars: Vars{
+			{Name: "grid", Type: Short, Dimensions: []*Dimension{&dims[1], &dims[2]}},
+			{Name: "temp", Type: Float, Dimensions: []*Dimension{&dims[0], &dims[1], &dims[2]}},
+			{Name: "time", Type: Double, Dimensions: []*Dimension{&dims[0]}},
+		}.Map(),
+	}
+	f.ComputeSizes()
+	return f
+}
+
+func collectRuns(f File, v Var, s Slab) []Run {
+	var runs []Run
+	f.SlabRuns(v, s, func(r Run) error {
+		runs = append(runs, r)
+		return nil
+	})
+	return runs
+}
+
+func TestSlabRunsFixed(t *testing.T) {
+	f := slabFile()
+	grid := f.Vars.Get("grid")
+	off := int64(grid.Offset)
+
+	assert.Equal(t, []Run{{Offset: off, Len: 6, Index: 0, Step: 1}},
+		collectRuns(f, grid, f.WholeSlab(grid)))
+
+	assert.Equal(t, []Run{
+		{Offset: off + 2, Len: 2, Index: 0, Step: 1},
+		{Offset: off + 8, Len: 2, Index: 2, Step: 1},
+	}, collectRuns(f, grid, Slab{Start: []int64{0, 1}, Count: []int64{2, 2}}))
+
+	assert.Equal(t, []Run{
+		{Offset: off + 6, Len: 1, Index: 0, Step: 1},
+		{Offset: off + 10, Len: 1, Index: 1, Step: 1},
+	}, collectRuns(f, grid, Slab{Start: []int64{1, 0}, Count: []int64{1, 2}, Stride: []int64{1, 2}}))
+
+	// transposed in memory
+	assert.Equal(t, []Run{
+		{Offset: off, Len: 3, Index: 0, Step: 2},
+		{Offset: off + 6, Len: 3, Index: 1, Step: 2},
+	}, collectRuns(f, grid, Slab{Start: []int64{0, 0}, Count: []int64{2, 3}, IMap: []int64{1, 2}}))
+}
+
+func TestSlabRunsRecords(t *testing.T) {
+	f := slabFile()
+	temp := f.Vars.Get("temp")
+	off := int64(temp.Offset)
+	recSize := f.RecSize()
+	assert.Equal(t, int64(24+8), recSize)
+
+	assert.Equal(t, []Run{
+		{Offset: off + recSize, Len: 6, Index: 0, Step: 1},
+		{Offset: off + 3*recSize, Len: 6, Index: 6, Step: 1},
+	}, collectRuns(f, temp, Slab{Start: []int64{1, 0, 0}, Count: []int64{2, 2, 3}, Stride: []int64{2, 1, 1}}))
+
+	tm := f.Vars.Get("time")
+	assert.Equal(t, []Run{
+		{Offset: int64(tm.Offset) + 2*recSize, Len: 1, Index: 0, Step: 1},
+		{Offset: int64(tm.Offset) + 3*recSize, Len: 1, Index: 1, Step: 1},
+	}, collectRuns(f, tm, Slab{Start: []int64{2}, Count: []int64{2}}))
+}
+
+func TestCheckSlab(t *testing.T) {
+	f := slabFile()
+	temp := f.Vars.Get("temp")
+	assert.NoError(t, f.CheckSlab(temp, f.WholeSlab(temp), false))
+	assert.EqualError(t, f.CheckSlab(temp, Slab{Start: []int64{0}, Count: []int64{1}}, false),
+		"Slab for variable `temp` must have 3 start and count values")
+	assert.EqualError(t, f.CheckSlab(temp, Slab{Start: []int64{0, 0, 1}, Count: []int64{1, 1, 3}}, false),
+		"Index 3 out of bounds for dimension `x` of variable `temp`")
+	assert.EqualError(t, f.CheckSlab(temp, Slab{Start: []int64{0, 0, 0}, Count: []int64{1, 1, 2}, Stride: []int64{1, 1, 0}}, false),
+		"Stride for dimension `x` of variable `temp` must be positive")
+	assert.EqualError(t, f.CheckSlab(temp, Slab{Start: []int64{4, 0, 0}, Count: []int64{1, 1, 1}}, false),
+		"Index 4 out of bounds for dimension `time` of variable `temp`")
+	assert.NoError(t, f.CheckSlab(temp, Slab{Start: []int64{4, 0, 0}, Count: []int64{1, 1, 1}}, true))
+}
+
+func TestSlabMemLen(t *testing.T) {
+	assert.Equal(t, int64(6), Slab{Count: []int64{2, 3}}.MemLen())
+	assert.Equal(t, int64(6), Slab{Count: []int64{2, 3}, IMap: []int64{1, 2}}.MemLen())
+	assert.Equal(t, int64(0), Slab{Count: []int64{0, 3}, IMap: []int64{1, 2}}.MemLen())
+}
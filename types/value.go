@@ -0,0 +1,416 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Value contains a list of values of a netcdf type.
+// It's used to hold the values of attributes.
+//
+// Values are stored in a slice of the go type matching
+// their netcdf type (see FromValueType); NC_CHAR, NC_BYTE
+// and NC_UBYTE values are stored in a []byte.
+// The zero Value is an empty list of Unknown type.
+type Value struct {
+	typ  Type
+	data interface{}
+}
+
+// Text returns a Value of type NC_CHAR containing s.
+func Text(s string) Value {
+	return Value{Char, []byte(s)}
+}
+
+// Bytes returns a Value of type NC_BYTE.
+func Bytes(v ...byte) Value {
+	return Value{Byte, v}
+}
+
+// Shorts returns a Value of type NC_SHORT.
+func Shorts(v ...int16) Value {
+	return Value{Short, v}
+}
+
+// Ints returns a Value of type NC_INT.
+func Ints(v ...int32) Value {
+	return Value{Int, v}
+}
+
+// Floats returns a Value of type NC_FLOAT.
+func Floats(v ...float32) Value {
+	return Value{Float, v}
+}
+
+// Doubles returns a Value of type NC_DOUBLE.
+func Doubles(v ...float64) Value {
+	return Value{Double, v}
+}
+
+// UBytes returns a Value of type NC_UBYTE.
+func UBytes(v ...byte) Value {
+	return Value{UByte, v}
+}
+
+// UShorts returns a Value of type NC_USHORT.
+func UShorts(v ...uint16) Value {
+	return Value{UShort, v}
+}
+
+// UInts returns a Value of type NC_UINT.
+func UInts(v ...uint32) Value {
+	return Value{UInt, v}
+}
+
+// Int64s returns a Value of type NC_INT64.
+func Int64s(v ...int64) Value {
+	return Value{Int64, v}
+}
+
+// UInt64s returns a Value of type NC_UINT64.
+func UInt64s(v ...uint64) Value {
+	return Value{UInt64, v}
+}
+
+// ValueOf returns a Value containing values, whose
+// type is FromValueType[T]().
+func ValueOf[T BaseType](values []T) Value {
+	return Value{FromValueType[T](), values}
+}
+
+// NewValue returns a Value of type t containing data,
+// that must be a slice of the go type matching t,
+// or a string for NC_CHAR values.
+func NewValue(t Type, data interface{}) (Value, error) {
+	if s, ok := data.(string); ok && t == Char {
+		return Text(s), nil
+	}
+	ok := false
+	switch data.(type) {
+	case []byte:
+		ok = IsValueType[byte](t)
+	case []int16:
+		ok = IsValueType[int16](t)
+	case []int32:
+		ok = IsValueType[int32](t)
+	case []float32:
+		ok = IsValueType[float32](t)
+	case []float64:
+		ok = IsValueType[float64](t)
+	case []uint16:
+		ok = IsValueType[uint16](t)
+	case []uint32:
+		ok = IsValueType[uint32](t)
+	case []int64:
+		ok = IsValueType[int64](t)
+	case []uint64:
+		ok = IsValueType[uint64](t)
+	}
+	if !ok {
+		return Value{}, fmt.Errorf("Cannot use %T as values of type %s", data, t)
+	}
+	return Value{t, data}, nil
+}
+
+// Type returns the netcdf type of the values.
+func (v Value) Type() Type {
+	return v.typ
+}
+
+// Len returns the number of values.
+func (v Value) Len() int {
+	switch data := v.data.(type) {
+	case []byte:
+		return len(data)
+	case []int16:
+		return len(data)
+	case []int32:
+		return len(data)
+	case []float32:
+		return len(data)
+	case []float64:
+		return len(data)
+	case []uint16:
+		return len(data)
+	case []uint32:
+		return len(data)
+	case []int64:
+		return len(data)
+	case []uint64:
+		return len(data)
+	}
+	return 0
+}
+
+// Interface returns the slice containing the values.
+func (v Value) Interface() interface{} {
+	return v.data
+}
+
+// Float64s returns the values converted to float64.
+// It returns nil for NC_CHAR values.
+func (v Value) Float64s() []float64 {
+	if v.typ == Char {
+		return nil
+	}
+	switch data := v.data.(type) {
+	case []byte:
+		if v.typ == Byte {
+			return convert(data, func(x byte) float64 { return float64(int8(x)) })
+		}
+		return convert(data, func(x byte) float64 { return float64(x) })
+	case []int16:
+		return convert(data, func(x int16) float64 { return float64(x) })
+	case []int32:
+		return convert(data, func(x int32) float64 { return float64(x) })
+	case []float32:
+		return convert(data, func(x float32) float64 { return float64(x) })
+	case []float64:
+		return convert(data, func(x float64) float64 { return x })
+	case []uint16:
+		return convert(data, func(x uint16) float64 { return float64(x) })
+	case []uint32:
+		return convert(data, func(x uint32) float64 { return float64(x) })
+	case []int64:
+		return convert(data, func(x int64) float64 { return float64(x) })
+	case []uint64:
+		return convert(data, func(x uint64) float64 { return float64(x) })
+	}
+	return nil
+}
+
+// Ints returns the values converted to int.
+// Floating point values are truncated toward zero.
+// It returns nil for NC_CHAR values.
+func (v Value) Ints() []int {
+	if v.typ == Char {
+		return nil
+	}
+	switch data := v.data.(type) {
+	case []int64:
+		return convert(data, func(x int64) int { return int(x) })
+	case []uint64:
+		return convert(data, func(x uint64) int { return int(x) })
+	}
+	return convert(v.Float64s(), func(x float64) int { return int(x) })
+}
+
+// String returns the text of NC_CHAR values.
+// Values of other types are formatted and
+// separated by a comma.
+func (v Value) String() string {
+	if v.typ == Char {
+		return string(v.data.([]byte))
+	}
+
+	var res strings.Builder
+	v.each(func(i int, x interface{}) {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		res.WriteString(v.typ.ValueToString(x))
+	})
+	return res.String()
+}
+
+// each calls fn for each value, passing it as
+// a go value of the type matching the netcdf type.
+// NC_BYTE values are passed as int8.
+func (v Value) each(fn func(i int, x interface{})) {
+	switch data := v.data.(type) {
+	case []byte:
+		for i, x := range data {
+			if v.typ == Byte {
+				fn(i, int8(x))
+			} else {
+				fn(i, x)
+			}
+		}
+	case []int16:
+		eachOf(data, fn)
+	case []int32:
+		eachOf(data, fn)
+	case []float32:
+		eachOf(data, fn)
+	case []float64:
+		eachOf(data, fn)
+	case []uint16:
+		eachOf(data, fn)
+	case []uint32:
+		eachOf(data, fn)
+	case []int64:
+		eachOf(data, fn)
+	case []uint64:
+		eachOf(data, fn)
+	}
+}
+
+// Convert returns the values converted to type t.
+// Numeric values can be converted to any other numeric
+// type: floating point values are truncated toward zero
+// when converted to an integer type, and an error is returned
+// if a value is out of the range of t.
+// NC_CHAR values can't be converted to or from other types.
+func (v Value) Convert(t Type) (Value, error) {
+	if t == v.typ {
+		return v, nil
+	}
+	if t == Char || v.typ == Char {
+		return Value{}, fmt.Errorf("Cannot convert values of type %s to %s", v.typ, t)
+	}
+
+	// 64 bits integers are converted directly,
+	// to not lose precision going through float64.
+	switch data := v.data.(type) {
+	case []int64:
+		if t == UInt64 {
+			for _, x := range data {
+				if x < 0 {
+					return Value{}, fmt.Errorf("Value %d out of range of type %s", x, t)
+				}
+			}
+			return UInt64s(convert(data, func(x int64) uint64 { return uint64(x) })...), nil
+		}
+	case []uint64:
+		if t == Int64 {
+			for _, x := range data {
+				if x > math.MaxInt64 {
+					return Value{}, fmt.Errorf("Value %d out of range of type %s", x, t)
+				}
+			}
+			return Int64s(convert(data, func(x uint64) int64 { return int64(x) })...), nil
+		}
+	}
+
+	values := v.Float64s()
+	var err error
+	check := func(x float64, min, max float64) {
+		if err == nil && (math.IsNaN(x) || x < min || x > max) {
+			err = fmt.Errorf("Value %g out of range of type %s", x, t)
+		}
+	}
+
+	var res Value
+	switch t {
+	case Byte:
+		res = Bytes(convert(values, func(x float64) byte { check(x, math.MinInt8, math.MaxInt8); return byte(int8(x)) })...)
+	case UByte:
+		res = UBytes(convert(values, func(x float64) byte { check(x, 0, math.MaxUint8); return byte(x) })...)
+	case Short:
+		res = Shorts(convert(values, func(x float64) int16 { check(x, math.MinInt16, math.MaxInt16); return int16(x) })...)
+	case UShort:
+		res = UShorts(convert(values, func(x float64) uint16 { check(x, 0, math.MaxUint16); return uint16(x) })...)
+	case Int:
+		res = Ints(convert(values, func(x float64) int32 { check(x, math.MinInt32, math.MaxInt32); return int32(x) })...)
+	case UInt:
+		res = UInts(convert(values, func(x float64) uint32 { check(x, 0, math.MaxUint32); return uint32(x) })...)
+	case Int64:
+		res = Int64s(convert(values, func(x float64) int64 { check(x, math.MinInt64, math.MaxInt64); return int64(x) })...)
+	case UInt64:
+		res = UInt64s(convert(values, func(x float64) uint64 { check(x, 0, math.MaxUint64); return uint64(x) })...)
+	case Float:
+		res = Floats(convert(values, func(x float64) float32 {
+			if !math.IsInf(x, 0) && !math.IsNaN(x) {
+				check(x, -math.MaxFloat32, math.MaxFloat32)
+			}
+			return float32(x)
+		})...)
+	case Double:
+		res = Doubles(values...)
+	default:
+		return Value{}, fmt.Errorf("Cannot convert values of type %s to %s", v.typ, t)
+	}
+	if err != nil {
+		return Value{}, err
+	}
+	return res, nil
+}
+
+// Equal returns whether v and other have the
+// same type and values. NaN values are
+// considered equal to each other.
+func (v Value) Equal(other Value) bool {
+	if v.typ != other.typ || v.Len() != other.Len() {
+		return false
+	}
+	if v.typ == Char {
+		return v.String() == other.String()
+	}
+	if v.typ == Float || v.typ == Double {
+		a, b := v.Float64s(), other.Float64s()
+		for i := range a {
+			if a[i] != b[i] && !(math.IsNaN(a[i]) && math.IsNaN(b[i])) {
+				return false
+			}
+		}
+		return true
+	}
+	equal := true
+	values := other.valuesList()
+	v.each(func(i int, x interface{}) {
+		equal = equal && x == values[i]
+	})
+	return equal
+}
+
+// Append returns a Value of type t containing all
+// values, that must be of type t too.
+func Append(t Type, values ...Value) (Value, error) {
+	switch t {
+	case Byte, UByte, Char:
+		return appendValues[byte](t, values)
+	case Short:
+		return appendValues[int16](t, values)
+	case Int:
+		return appendValues[int32](t, values)
+	case Float:
+		return appendValues[float32](t, values)
+	case Double:
+		return appendValues[float64](t, values)
+	case UShort:
+		return appendValues[uint16](t, values)
+	case UInt:
+		return appendValues[uint32](t, values)
+	case Int64:
+		return appendValues[int64](t, values)
+	case UInt64:
+		return appendValues[uint64](t, values)
+	}
+	return Value{}, fmt.Errorf("Cannot append values of type %s", t)
+}
+
+func appendValues[T BaseType](t Type, values []Value) (Value, error) {
+	res := []T{}
+	for _, v := range values {
+		if v.typ != t {
+			return Value{}, fmt.Errorf("Cannot append values of type %s to values of type %s", v.typ, t)
+		}
+		if v.Len() > 0 {
+			res = append(res, v.data.([]T)...)
+		}
+	}
+	return Value{t, res}, nil
+}
+
+func (v Value) valuesList() []interface{} {
+	res := make([]interface{}, v.Len())
+	v.each(func(i int, x interface{}) {
+		res[i] = x
+	})
+	return res
+}
+
+func convert[T, R any](data []T, fn func(T) R) []R {
+	res := make([]R, len(data))
+	for i, x := range data {
+		res[i] = fn(x)
+	}
+	return res
+}
+
+func eachOf[T BaseType](data []T, fn func(i int, x interface{})) {
+	for i, x := range data {
+		fn(i, x)
+	}
+}
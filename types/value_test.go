@@ -0,0 +1,123 @@
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueConstructors(t *testing.T) {
+	assert.Equal(t, Char, Text("ciao").Type())
+	assert.Equal(t, 4, Text("ciao").Len())
+	assert.Equal(t, Byte, Bytes(1).Type())
+	assert.Equal(t, UByte, UBytes(1).Type())
+	assert.Equal(t, Short, Shorts(1, 2).Type())
+	assert.Equal(t, Int, Ints(1).Type())
+	assert.Equal(t, Float, Floats(1).Type())
+	assert.Equal(t, Double, Doubles(1).Type())
+	assert.Equal(t, UShort, UShorts(1).Type())
+	assert.Equal(t, UInt, UInts(1).Type())
+	assert.Equal(t, Int64, Int64s(1).Type())
+	assert.Equal(t, UInt64, UInt64s(1).Type())
+	assert.Equal(t, Double, ValueOf([]float64{1}).Type())
+	assert.Equal(t, Unknown, Value{}.Type())
+	assert.Equal(t, 0, Value{}.Len())
+
+	v, err := NewValue(Char, "ciao")
+	require.NoError(t, err)
+	assert.Equal(t, Text("ciao"), v)
+
+	v, err = NewValue(UByte, []byte{1})
+	require.NoError(t, err)
+	assert.Equal(t, UBytes(1), v)
+
+	_, err = NewValue(Float, []float64{1})
+	assert.EqualError(t, err, "Cannot use []float64 as values of type NC_FLOAT")
+}
+
+func TestValueAccessors(t *testing.T) {
+	assert.Equal(t, []float64{-1, 2}, Bytes(255, 2).Float64s())
+	assert.Equal(t, []float64{255, 2}, UBytes(255, 2).Float64s())
+	assert.Equal(t, []float64{0.5, 100}, Floats(0.5, 100).Float64s())
+	assert.Nil(t, Text("ciao").Float64s())
+
+	assert.Equal(t, []int{0, -100}, Doubles(0.9, -100.2).Ints())
+	assert.Equal(t, []int{1 << 60}, Int64s(1<<60).Ints())
+	assert.Nil(t, Text("ciao").Ints())
+
+	assert.Equal(t, "ciao", Text("ciao").String())
+	assert.Equal(t, "0.5, 100", Floats(0.5, 100).String())
+	assert.Equal(t, "-1, 2", Bytes(255, 2).String())
+	assert.Equal(t, []int16{1, 2}, Shorts(1, 2).Interface())
+}
+
+func TestValueConvert(t *testing.T) {
+	v, err := Shorts(1, -2).Convert(Double)
+	require.NoError(t, err)
+	assert.Equal(t, Doubles(1, -2), v)
+
+	v, err = Doubles(1.7, -2.7).Convert(Int)
+	require.NoError(t, err)
+	assert.Equal(t, Ints(1, -2), v)
+
+	v, err = Ints(-1).Convert(Byte)
+	require.NoError(t, err)
+	assert.Equal(t, Bytes(255), v)
+
+	v, err = Int64s(math.MaxInt64).Convert(UInt64)
+	require.NoError(t, err)
+	assert.Equal(t, UInt64s(math.MaxInt64), v)
+
+	_, err = Ints(-1).Convert(UByte)
+	assert.EqualError(t, err, "Value -1 out of range of type NC_UBYTE")
+	_, err = Ints(40000).Convert(Short)
+	assert.EqualError(t, err, "Value 40000 out of range of type NC_SHORT")
+	_, err = UInt64s(math.MaxUint64).Convert(Int64)
+	assert.EqualError(t, err, "Value 18446744073709551615 out of range of type NC_INT64")
+	_, err = Doubles(math.NaN()).Convert(Int)
+	assert.EqualError(t, err, "Value NaN out of range of type NC_INT")
+	_, err = Text("a").Convert(Byte)
+	assert.EqualError(t, err, "Cannot convert values of type NC_CHAR to NC_BYTE")
+
+	v, err = Doubles(math.Inf(1)).Convert(Float)
+	require.NoError(t, err)
+	assert.True(t, math.IsInf(v.Float64s()[0], 1))
+}
+
+func TestValueEqual(t *testing.T) {
+	assert.True(t, Floats(1, float32(math.NaN())).Equal(Floats(1, float32(math.NaN()))))
+	assert.False(t, Floats(1).Equal(Doubles(1)))
+	assert.False(t, Shorts(1, 2).Equal(Shorts(1, 3)))
+	assert.True(t, Text("a").Equal(Text("a")))
+	assert.True(t, UInt64s(1<<63).Equal(UInt64s(1<<63)))
+}
+
+func TestAttrHelpers(t *testing.T) {
+	f := File{Attrs: Attrs{{Name: "title", Val: Text("test")}}.Map()}
+	title, ok := f.Attr("title")
+	assert.True(t, ok)
+	assert.Equal(t, "test", title.String())
+	assert.Equal(t, Char, f.Attrs.Get("title").Type())
+
+	v := Var{Attrs: Attrs{{Name: "scale_factor", Val: Floats(0.01)}}.Map()}
+	sf, ok := v.Attr("scale_factor")
+	assert.True(t, ok)
+	assert.InDelta(t, 0.01, sf.Float64s()[0], 1e-9)
+	_, ok = v.Attr("add_offset")
+	assert.False(t, ok)
+}
+
+func TestAppend(t *testing.T) {
+	v, err := Append(Short, Shorts(1, 2), Shorts(), Shorts(3))
+	require.NoError(t, err)
+	assert.Equal(t, Shorts(1, 2, 3), v)
+
+	v, err = Append(Char, Text("ab"), Text("c"))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", v.String())
+
+	_, err = Append(Short, Shorts(1), Ints(2))
+	assert.EqualError(t, err, "Cannot append values of type NC_INT to values of type NC_SHORT")
+}
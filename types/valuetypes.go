@@ -20,6 +20,16 @@ const (
 	Float Type = 5
 	// Double is type NC_DOUBLE = \x00 \x00 \x00 \x06 // IEEE double precision floats
 	Double Type = 6
+	// UByte is type NC_UBYTE = \x00 \x00 \x00 \x07 // 8-bit unsigned integers (CDF-5 only)
+	UByte Type = 7
+	// UShort is type NC_USHORT = \x00 \x00 \x00 \x08 // 16-bit unsigned integers (CDF-5 only)
+	UShort Type = 8
+	// UInt is type NC_UINT = \x00 \x00 \x00 \x09 // 32-bit unsigned integers (CDF-5 only)
+	UInt Type = 9
+	// Int64 is type NC_INT64 = \x00 \x00 \x00 \x0A // 64-bit signed integers (CDF-5 only)
+	Int64 Type = 10
+	// UInt64 is type NC_UINT64 = \x00 \x00 \x00 \x0B // 64-bit unsigned integers (CDF-5 only)
+	UInt64 Type = 11
 )
 
 func (t Type) CDLName() string {
@@ -36,6 +46,16 @@ func (t Type) CDLName() string {
 		return "float"
 	case Double:
 		return "double"
+	case UByte:
+		return "ubyte"
+	case UShort:
+		return "ushort"
+	case UInt:
+		return "uint"
+	case Int64:
+		return "int64"
+	case UInt64:
+		return "uint64"
 	}
 
 	return fmt.Sprintf("[unknown type:%d]", t)
@@ -54,10 +74,30 @@ func FromValueType[T BaseType]() Type {
 		return Short
 	case byte:
 		return Byte
+	case uint16:
+		return UShort
+	case uint32:
+		return UInt
+	case int64:
+		return Int64
+	case uint64:
+		return UInt64
 	}
 	return Unknown
 }
 
+// IsValueType returns whether values of type t
+// can be stored in a go value of type T.
+// byte values can hold NC_BYTE, NC_UBYTE and NC_CHAR
+// values.
+func IsValueType[T BaseType](t Type) bool {
+	vt := FromValueType[T]()
+	if vt == Byte {
+		return t == Byte || t == UByte || t == Char
+	}
+	return vt == t
+}
+
 func FromCDLName(typeName string) Type {
 	switch typeName {
 	case "float":
@@ -72,6 +112,16 @@ func FromCDLName(typeName string) Type {
 		return Int
 	case "double":
 		return Double
+	case "ubyte":
+		return UByte
+	case "ushort":
+		return UShort
+	case "uint":
+		return UInt
+	case "int64":
+		return Int64
+	case "uint64":
+		return UInt64
 	}
 
 	return Unknown
@@ -80,7 +130,7 @@ func FromCDLName(typeName string) Type {
 func (t Type) ValueToString(value interface{}) string {
 	var format string
 	switch t {
-	case Byte, Short, Int:
+	case Byte, Short, Int, UByte, UShort, UInt, Int64, UInt64:
 		format = "%d"
 	case Char:
 		format = "%s"
@@ -107,14 +157,27 @@ func (t Type) String() string {
 		return "NC_FLOAT"
 	case Double:
 		return "NC_DOUBLE"
+	case UByte:
+		return "NC_UBYTE"
+	case UShort:
+		return "NC_USHORT"
+	case UInt:
+		return "NC_UINT"
+	case Int64:
+		return "NC_INT64"
+	case UInt64:
+		return "NC_UINT64"
 	}
 
 	return fmt.Sprintf("[UNKNOWN TYPE:%d]", t)
 }
 
 // BaseType ...
+// NC_UBYTE values have no dedicated go type,
+// and are represented as byte like NC_BYTE ones.
 type BaseType interface {
-	byte | int16 | int32 | float32 | float64
+	byte | int16 | int32 | float32 | float64 |
+		uint16 | uint32 | int64 | uint64
 }
 
 // AlignForArrayOf returns the size in bytes of
@@ -156,13 +219,13 @@ func (t Type) ArraySize(n int) int {
 func (t Type) ScalarSize() int {
 	var sz int
 	switch t {
-	case Double:
+	case Double, Int64, UInt64:
 		sz = 8
-	case Short:
+	case Short, UShort:
 		sz = 2
-	case Int:
+	case Int, UInt:
 		sz = 4
-	case Byte:
+	case Byte, UByte:
 		sz = 1
 	case Float:
 		sz = 4
@@ -13,6 +13,11 @@ func TestValue(t *testing.T) {
 	assert.Equal(t, 4, Int.ScalarSize())
 	assert.Equal(t, 4, Float.ScalarSize())
 	assert.Equal(t, 8, Double.ScalarSize())
+	assert.Equal(t, 1, UByte.ScalarSize())
+	assert.Equal(t, 2, UShort.ScalarSize())
+	assert.Equal(t, 4, UInt.ScalarSize())
+	assert.Equal(t, 8, Int64.ScalarSize())
+	assert.Equal(t, 8, UInt64.ScalarSize())
 }
 
 func TestAlignForArrayOf(t *testing.T) {
@@ -116,6 +121,11 @@ func TestStringer(t *testing.T) {
 	assert.Equal(t, "NC_INT", Int.String())
 	assert.Equal(t, "NC_FLOAT", Float.String())
 	assert.Equal(t, "NC_DOUBLE", Double.String())
+	assert.Equal(t, "NC_UBYTE", UByte.String())
+	assert.Equal(t, "NC_USHORT", UShort.String())
+	assert.Equal(t, "NC_UINT", UInt.String())
+	assert.Equal(t, "NC_INT64", Int64.String())
+	assert.Equal(t, "NC_UINT64", UInt64.String())
 	assert.Equal(t, "[UNKNOWN TYPE:666]", Type(666).String())
 }
 
@@ -126,6 +136,7 @@ func TestValueToString(t *testing.T) {
 	assert.Equal(t, "42", Int.ValueToString(42))
 	assert.Equal(t, "42.42", Float.ValueToString(42.42))
 	assert.Equal(t, "42.42", Double.ValueToString(42.42))
+	assert.Equal(t, "42", UInt64.ValueToString(uint64(42)))
 	assert.Equal(t, "[UNKNOWN TYPE:666. VALUE: <nil>]", Type(666).ValueToString(nil))
 
 }
@@ -137,6 +148,11 @@ func TestCDLName(t *testing.T) {
 	assert.Equal(t, "short", Short.CDLName())
 	assert.Equal(t, "char", Char.CDLName())
 	assert.Equal(t, "byte", Byte.CDLName())
+	assert.Equal(t, "ubyte", UByte.CDLName())
+	assert.Equal(t, "ushort", UShort.CDLName())
+	assert.Equal(t, "uint", UInt.CDLName())
+	assert.Equal(t, "int64", Int64.CDLName())
+	assert.Equal(t, "uint64", UInt64.CDLName())
 	assert.Equal(t, "[unknown type:666]", Type(666).CDLName())
 }
 
@@ -147,6 +163,11 @@ func TestFromCDLName(t *testing.T) {
 	assert.Equal(t, Short, FromCDLName("short"))
 	assert.Equal(t, Char, FromCDLName("char"))
 	assert.Equal(t, Byte, FromCDLName("byte"))
+	assert.Equal(t, UByte, FromCDLName("ubyte"))
+	assert.Equal(t, UShort, FromCDLName("ushort"))
+	assert.Equal(t, UInt, FromCDLName("uint"))
+	assert.Equal(t, Int64, FromCDLName("int64"))
+	assert.Equal(t, UInt64, FromCDLName("uint64"))
 	assert.Equal(t, Unknown, FromCDLName("other"))
 
 }
@@ -158,6 +179,20 @@ func TestFromValueType(t *testing.T) {
 	assert.Equal(t, Short, FromValueType[int16]())
 	//assert.Equal(t, Char, FromValueType[rune]())
 	assert.Equal(t, Byte, FromValueType[byte]())
+	assert.Equal(t, UShort, FromValueType[uint16]())
+	assert.Equal(t, UInt, FromValueType[uint32]())
+	assert.Equal(t, Int64, FromValueType[int64]())
+	assert.Equal(t, UInt64, FromValueType[uint64]())
 	//assert.Equal(t, Unknown, FromValueType[byte]())
 
 }
+
+func TestIsValueType(t *testing.T) {
+	assert.True(t, IsValueType[byte](Byte))
+	assert.True(t, IsValueType[byte](UByte))
+	assert.True(t, IsValueType[byte](Char))
+	assert.True(t, IsValueType[float32](Float))
+	assert.True(t, IsValueType[uint64](UInt64))
+	assert.False(t, IsValueType[float64](Float))
+	assert.False(t, IsValueType[int16](UShort))
+}
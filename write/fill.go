@@ -0,0 +1,92 @@
+package write
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/parro-it/ncdf/types"
+)
+
+// fillChunkSize is the maximum number of bytes
+// written at once when filling variables.
+const fillChunkSize = 64 * 1024
+
+// Fill pre-fills all variables of f with their fill value,
+// that is the value of their _FillValue attribute
+// or the default fill value for their type.
+// Fixed variables are filled entirely, record variables
+// are filled for the first f.NumRecs records.
+// Offsets of variables must be already computed,
+// see types.File.ComputeSizes.
+func Fill(f *types.File, fd io.WriterAt) error {
+	for _, v := range f.Vars.Values() {
+		if err := FillVar(f, v, fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FillVar pre-fills all values of variable v of file f
+// with its fill value.
+func FillVar(f *types.File, v types.Var, fd io.WriterAt) error {
+	if !v.IsRecord() {
+		return fillValues(v, v.RecordLen(), int64(v.Offset), fd)
+	}
+	return fillRecords(f, v, 0, f.NumRecs, fd)
+}
+
+// FillRecords pre-fills records from from to to (excluded)
+// of all record variables of f with their fill value.
+func FillRecords(f *types.File, from, to int64, fd io.WriterAt) error {
+	for _, v := range f.RecordVars() {
+		if err := fillRecords(f, v, from, to, fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fillRecords(f *types.File, v types.Var, from, to int64, fd io.WriterAt) error {
+	recSize := f.RecSize()
+	for r := from; r < to; r++ {
+		if err := fillValues(v, v.RecordLen(), int64(v.Offset)+r*recSize, fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillValues writes n fill values of variable v
+// starting at offset.
+func fillValues(v types.Var, n int64, offset int64, fd io.WriterAt) error {
+	fill, err := v.FillValue()
+	if err != nil {
+		return err
+	}
+	var one bytes.Buffer
+	if err := binary.Write(&one, binary.BigEndian, fill.Interface()); err != nil {
+		return err
+	}
+
+	scalar := int64(one.Len())
+	perChunk := fillChunkSize / scalar
+	if perChunk > n {
+		perChunk = n
+	}
+	chunk := bytes.Repeat(one.Bytes(), int(perChunk))
+
+	for n > 0 {
+		count := perChunk
+		if count > n {
+			count = n
+		}
+		if _, err := fd.WriteAt(chunk[:count*scalar], offset); err != nil {
+			return err
+		}
+		offset += count * scalar
+		n -= count
+	}
+	return nil
+}
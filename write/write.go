@@ -3,33 +3,167 @@ package write
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
 
 	"github.com/parro-it/ncdf/ordmap"
 	"github.com/parro-it/ncdf/types"
 )
 
-// VarData ...
-// TODO: use missing value for data
-func VarData[T types.BaseType](v types.Var, data []T, fd io.WriterAt) error {
+// VarData writes all values of variable v of file f.
+// Values of record variables are split in records
+// of v.RecordLen() values each: when they span more
+// than f.NumRecs records, the new records of all record
+// variables are pre-filled (see FillRecords) and f.NumRecs is
+// updated both in f and in the header already written in fd.
+// Values of other variables must be exactly v.RecordLen().
+// It returns an error if values of type T cannot be
+// stored in v.
+func VarData[T types.BaseType](f *types.File, v types.Var, data []T, fd io.WriterAt) error {
+	if err := checkValueType[T](v); err != nil {
+		return err
+	}
+	if !v.IsRecord() {
+		if int64(len(data)) != v.RecordLen() {
+			return fmt.Errorf("Variable `%s` has %d values, got %d", v.Name, v.RecordLen(), len(data))
+		}
+		return writeAt(fd, data, int64(v.Offset))
+	}
+
+	recLen := v.RecordLen()
+	if int64(len(data))%recLen != 0 {
+		return fmt.Errorf("Data for record variable `%s` must contain a multiple of %d values, got %d", v.Name, recLen, len(data))
+	}
+	numRecs := int64(len(data)) / recLen
+	if err := growRecords(f, numRecs, fd); err != nil {
+		return err
+	}
+
+	recSize := f.RecSize()
+	for r := int64(0); r < numRecs; r++ {
+		if err := writeAt(fd, data[r*recLen:(r+1)*recLen], int64(v.Offset)+r*recSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Slab writes values of variable v of file f
+// selected by s, taking them from data in the order
+// given by s.IMap, or in row-major order of s.Count
+// when s.IMap is nil.
+// Only the byte ranges of selected values are written in fd.
+// When s selects records beyond f.NumRecs, the new records of
+// all record variables are pre-filled (see FillRecords) and
+// f.NumRecs is updated both in f and in the header already
+// written in fd.
+// It returns an error if values of type T cannot be
+// stored in v.
+func Slab[T types.BaseType](f *types.File, v types.Var, s types.Slab, data []T, fd io.WriterAt) error {
+	if err := checkValueType[T](v); err != nil {
+		return err
+	}
+	if err := f.CheckSlab(v, s, true); err != nil {
+		return err
+	}
+	if int64(len(data)) < s.MemLen() {
+		return fmt.Errorf("Slab for variable `%s` needs %d values, got %d", v.Name, s.MemLen(), len(data))
+	}
+
+	if v.IsRecord() && s.Count[0] > 0 {
+		var stride int64 = 1
+		if s.Stride != nil {
+			stride = s.Stride[0]
+		}
+		if err := growRecords(f, s.Start[0]+(s.Count[0]-1)*stride+1, fd); err != nil {
+			return err
+		}
+	}
+
+	var buf []T
+	return f.SlabRuns(v, s, func(r types.Run) error {
+		if r.Step == 1 {
+			return writeAt(fd, data[r.Index:r.Index+r.Len], r.Offset)
+		}
+		buf = buf[:0]
+		for i := int64(0); i < r.Len; i++ {
+			buf = append(buf, data[r.Index+i*r.Step])
+		}
+		return writeAt(fd, buf, r.Offset)
+	})
+}
+
+// checkValueType returns an error if values
+// of type T cannot be stored in variable v.
+func checkValueType[T types.BaseType](v types.Var) error {
+	if !types.IsValueType[T](v.Type) {
+		var empty T
+		return fmt.Errorf("Cannot write values of type %T in variable `%s` of type %s", empty, v.Name, v.Type)
+	}
+	return nil
+}
+
+// growRecords pre-fills records of f from f.NumRecs
+// to numRecs and updates f.NumRecs, when numRecs
+// is greater than it. A streaming number of records
+// counts as 0.
+func growRecords(f *types.File, numRecs int64, fd io.WriterAt) error {
+	from := f.NumRecs
+	if from == types.Streaming {
+		from = 0
+	}
+	if numRecs <= from {
+		return nil
+	}
+	if err := FillRecords(f, from, numRecs, fd); err != nil {
+		return err
+	}
+	f.NumRecs = numRecs
+	return NumRecs(f, fd)
+}
+
+// NumRecs updates the number of records
+// in the header of f written in fd.
+func NumRecs(f *types.File, fd io.WriterAt) error {
+	var buf bytes.Buffer
+	if err := writeCount(f.Version.OrDefault(), &buf, f.NumRecs); err != nil {
+		return err
+	}
+	// numrecs follows magic & version
+	if _, err := fd.WriteAt(buf.Bytes(), 4); err != nil {
+		return err
+	}
+	return nil
+}
+
+func writeAt[T types.BaseType](fd io.WriterAt, data []T, offset int64) error {
 	var buf bytes.Buffer
 	if err := binary.Write(&buf, binary.BigEndian, data); err != nil {
 		return err
 	}
-	if _, err := fd.WriteAt(buf.Bytes(), int64(v.Offset)); err != nil {
+	if _, err := fd.WriteAt(buf.Bytes(), offset); err != nil {
 		return err
 	}
 	return nil
 }
 
 func Header(f *types.File, w io.Writer) error {
+	ver := f.Version.OrDefault()
+	if err := ver.Check(); err != nil {
+		return err
+	}
+
+	if err := checkTypes(ver, f); err != nil {
+		return err
+	}
+
 	// magic + version
-	bytes := []byte{'C', 'D', 'F', 2}
-	if _, err := w.Write(bytes); err != nil {
+	if _, err := w.Write(ver[:]); err != nil {
 		return err
 	}
 
-	if err := binary.Write(w, binary.BigEndian, f.NumRecs); err != nil {
+	if err := writeCount(ver, w, f.NumRecs); err != nil {
 		return err
 	}
 	// dimensions
@@ -38,7 +172,7 @@ func Header(f *types.File, w io.Writer) error {
 		if err := writeTag(types.ZeroTag, w); err != nil {
 			return err
 		}
-		if err := binary.Write(w, binary.BigEndian, int32(0)); err != nil {
+		if err := writeCount(ver, w, 0); err != nil {
 			return err
 		}
 	} else {
@@ -48,19 +182,19 @@ func Header(f *types.File, w io.Writer) error {
 			return err
 		}
 
-		if err := binary.Write(w, binary.BigEndian, int32(len(f.Dimensions))); err != nil {
+		if err := writeCount(ver, w, int64(len(f.Dimensions))); err != nil {
 			return err
 		}
 
 		for _, d := range f.Dimensions {
-			if err := writeDimension(d, w); err != nil {
+			if err := writeDimension(ver, d, w); err != nil {
 				return err
 			}
 		}
 	}
 	// attrs
 
-	if err := writeAttrs(w, f.Attrs); err != nil {
+	if err := writeAttrs(ver, w, f.Attrs); err != nil {
 		return err
 	}
 
@@ -69,7 +203,7 @@ func Header(f *types.File, w io.Writer) error {
 		if err := writeTag(types.ZeroTag, w); err != nil {
 			return err
 		}
-		if err := binary.Write(w, binary.BigEndian, int32(0)); err != nil {
+		if err := writeCount(ver, w, 0); err != nil {
 			return err
 		}
 	} else {
@@ -77,12 +211,12 @@ func Header(f *types.File, w io.Writer) error {
 			return err
 		}
 
-		if err := binary.Write(w, binary.BigEndian, int32(f.Vars.Len())); err != nil {
+		if err := writeCount(ver, w, int64(f.Vars.Len())); err != nil {
 			return err
 		}
 
 		for _, v := range f.Vars.Values() {
-			if err := writeVar(f, v, w); err != nil {
+			if err := writeVar(ver, f, v, w); err != nil {
 				return err
 			}
 		}
@@ -91,6 +225,44 @@ func Header(f *types.File, w io.Writer) error {
 	return nil
 }
 
+// checkTypes returns an error if some attribute
+// or variable of f has a type that cannot
+// be stored in a file of version ver.
+func checkTypes(ver types.Version, f *types.File) error {
+	check := func(t types.Type, name string) error {
+		if !ver.Supports(t) {
+			return fmt.Errorf("Type %s of `%s` is not supported by version %d", t, name, ver[3])
+		}
+		return nil
+	}
+	for _, a := range f.Attrs.Values() {
+		if err := check(a.Type(), a.Name); err != nil {
+			return err
+		}
+	}
+	for _, v := range f.Vars.Values() {
+		if err := check(v.Type, v.Name); err != nil {
+			return err
+		}
+		for _, a := range v.Attrs.Values() {
+			if err := check(a.Type(), v.Name+":"+a.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCount writes a non negative count,
+// using 64 bits for CDF-5 files and 32 bits
+// otherwise.
+func writeCount(ver types.Version, w io.Writer, n int64) error {
+	if ver.CountSize() == 8 {
+		return binary.Write(w, binary.BigEndian, n)
+	}
+	return binary.Write(w, binary.BigEndian, uint32(n))
+}
+
 func writeTag(tag types.Tag, w io.Writer) error {
 	buf := []byte{0, 0, 0, byte(tag)}
 	if _, err := w.Write(buf); err != nil {
@@ -99,12 +271,12 @@ func writeTag(tag types.Tag, w io.Writer) error {
 	return nil
 }
 
-func writeAttrs(w io.Writer, attrs ordmap.OrderedMap[types.Attr, string]) error {
+func writeAttrs(ver types.Version, w io.Writer, attrs ordmap.OrderedMap[types.Attr, string]) error {
 	if attrs.Len() == 0 {
 		if err := writeTag(types.ZeroTag, w); err != nil {
 			return err
 		}
-		if err := binary.Write(w, binary.BigEndian, int32(0)); err != nil {
+		if err := writeCount(ver, w, 0); err != nil {
 			return err
 		}
 		return nil
@@ -113,27 +285,27 @@ func writeAttrs(w io.Writer, attrs ordmap.OrderedMap[types.Attr, string]) error
 		return err
 	}
 
-	if err := binary.Write(w, binary.BigEndian, int32(attrs.Len())); err != nil {
+	if err := writeCount(ver, w, int64(attrs.Len())); err != nil {
 		return err
 	}
 
 	for _, a := range attrs.Values() {
-		if err := writeAttr(a, w); err != nil {
+		if err := writeAttr(ver, a, w); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func writeAttr(a types.Attr, w io.Writer) error {
-	if err := writeSlice(w, []byte(a.Name)); err != nil {
+func writeAttr(ver types.Version, a types.Attr, w io.Writer) error {
+	if err := writeSlice(ver, w, []byte(a.Name)); err != nil {
 		return err
 	}
-	if err := binary.Write(w, binary.BigEndian, a.Type); err != nil {
+	if err := binary.Write(w, binary.BigEndian, a.Type()); err != nil {
 		return err
 	}
 
-	err := writeAttrValue(a, w)
+	err := writeAttrValue(ver, a, w)
 	if err != nil {
 		return err
 	}
@@ -141,8 +313,8 @@ func writeAttr(a types.Attr, w io.Writer) error {
 	return nil
 }
 
-func writeSlice[T types.BaseType](w io.Writer, val []T) error {
-	if err := binary.Write(w, binary.BigEndian, int32(len(val))); err != nil {
+func writeSlice[T types.BaseType](ver types.Version, w io.Writer, val []T) error {
+	if err := writeCount(ver, w, int64(len(val))); err != nil {
 		return err
 	}
 
@@ -160,13 +332,36 @@ func writeSlice[T types.BaseType](w io.Writer, val []T) error {
 	return nil
 }
 
-func writeAttrValue(a types.Attr, w io.Writer) error {
-	values := a.Val.([]int16)
-	return writeSlice(w, values)
+// writeAttrValue writes all values of attribute a.
+func writeAttrValue(ver types.Version, a types.Attr, w io.Writer) error {
+	switch val := a.Val.Interface().(type) {
+	case nil:
+		return writeCount(ver, w, 0)
+	case []byte:
+		return writeSlice(ver, w, val)
+	case []int16:
+		return writeSlice(ver, w, val)
+	case []int32:
+		return writeSlice(ver, w, val)
+	case []float32:
+		return writeSlice(ver, w, val)
+	case []float64:
+		return writeSlice(ver, w, val)
+	case []uint16:
+		return writeSlice(ver, w, val)
+	case []uint32:
+		return writeSlice(ver, w, val)
+	case []int64:
+		return writeSlice(ver, w, val)
+	case []uint64:
+		return writeSlice(ver, w, val)
+	}
+
+	return fmt.Errorf("Unsupported value of type %T for attribute `%s`", a.Val.Interface(), a.Name)
 }
 
-func writeVar(f *types.File, v types.Var, w io.Writer) error {
-	if err := binary.Write(w, binary.BigEndian, int32(len(v.Name))); err != nil {
+func writeVar(ver types.Version, f *types.File, v types.Var, w io.Writer) error {
+	if err := writeCount(ver, w, int64(len(v.Name))); err != nil {
 		return err
 	}
 	if _, err := w.Write([]byte(v.Name)); err != nil {
@@ -179,14 +374,14 @@ func writeVar(f *types.File, v types.Var, w io.Writer) error {
 			return err
 		}
 	}
-	if err := binary.Write(w, binary.BigEndian, int32(len(v.Dimensions))); err != nil {
+	if err := writeCount(ver, w, int64(len(v.Dimensions))); err != nil {
 		return err
 	}
 
-	findDim := func(d *types.Dimension) int32 {
+	findDim := func(d *types.Dimension) int64 {
 		for idx, dt := range f.Dimensions {
 			if d.Name == dt.Name {
-				return int32(idx)
+				return int64(idx)
 			}
 
 		}
@@ -194,12 +389,12 @@ func writeVar(f *types.File, v types.Var, w io.Writer) error {
 	}
 
 	for _, d := range v.Dimensions {
-		if err := binary.Write(w, binary.BigEndian, findDim(d)); err != nil {
+		if err := writeCount(ver, w, findDim(d)); err != nil {
 			return err
 		}
 	}
 
-	if err := writeAttrs(w, v.Attrs); err != nil {
+	if err := writeAttrs(ver, w, v.Attrs); err != nil {
 		return err
 	}
 
@@ -207,19 +402,42 @@ func writeVar(f *types.File, v types.Var, w io.Writer) error {
 		return err
 	}
 
-	if err := binary.Write(w, binary.BigEndian, v.Size); err != nil {
+	if err := writeVarSize(ver, w, v.Size); err != nil {
 		return err
 	}
 
-	if err := binary.Write(w, binary.BigEndian, v.Offset); err != nil {
+	if err := writeOffset(ver, w, v.Offset); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func writeDimension(d types.Dimension, w io.Writer) error {
-	if err := binary.Write(w, binary.BigEndian, int32(len(d.Name))); err != nil {
+// writeVarSize writes the vsize field of a variable.
+// CDF-1 and CDF-2 files store it in 32 bits: larger
+// sizes are written as 2^32 - 1, as the netcdf library does.
+func writeVarSize(ver types.Version, w io.Writer, sz int64) error {
+	if ver.CountSize() == 4 && sz > math.MaxUint32 {
+		sz = math.MaxUint32
+	}
+	return writeCount(ver, w, sz)
+}
+
+// writeOffset writes the begin offset of a variable,
+// using a signed 32 bits integer for CDF-1 files
+// and 64 bits otherwise.
+func writeOffset(ver types.Version, w io.Writer, offset uint64) error {
+	if ver.OffsetSize() == 8 {
+		return binary.Write(w, binary.BigEndian, offset)
+	}
+	if offset > math.MaxInt32 {
+		return fmt.Errorf("Offset %d is too large for version %d", offset, ver[3])
+	}
+	return binary.Write(w, binary.BigEndian, int32(offset))
+}
+
+func writeDimension(ver types.Version, d types.Dimension, w io.Writer) error {
+	if err := writeCount(ver, w, int64(len(d.Name))); err != nil {
 		return err
 	}
 	if err := binary.Write(w, binary.BigEndian, []byte(d.Name)); err != nil {
@@ -234,8 +452,114 @@ func writeDimension(d types.Dimension, w io.Writer) error {
 		}
 	}
 
-	if err := binary.Write(w, binary.BigEndian, d.Len); err != nil {
+	if err := writeCount(ver, w, d.Len); err != nil {
 		return err
 	}
 	return nil
 }
+
+// VarDataPacked packs data following the CF conventions,
+// using the scale_factor and add_offset attributes of v
+// (see types.Pack), and writes it as all values of variable v.
+// NaN values are written as the fill value of v.
+func VarDataPacked[T types.Floating](f *types.File, v types.Var, data []T, fd io.WriterAt) error {
+	packed, err := types.Pack(v, data)
+	if err != nil {
+		return err
+	}
+	return VarValue(f, v, packed, fd)
+}
+
+// VarValue writes val as the first values of variable v,
+// like VarData. Values are padded with the fill value of v
+// up to a whole record.
+func VarValue(f *types.File, v types.Var, val types.Value, fd io.WriterAt) error {
+	if val.Type() != v.Type {
+		return fmt.Errorf("Cannot write values of type %s in variable `%s` of type %s", val.Type(), v.Name, v.Type)
+	}
+	if !v.IsRecord() && int64(val.Len()) > v.RecordLen() {
+		return fmt.Errorf("Variable `%s` has %d values, got %d", v.Name, v.RecordLen(), val.Len())
+	}
+	if rest := int64(val.Len()) % v.RecordLen(); rest != 0 {
+		padded, err := padWithFill(v, val, v.RecordLen()-rest)
+		if err != nil {
+			return err
+		}
+		val = padded
+	}
+
+	switch data := val.Interface().(type) {
+	case []byte:
+		return VarData(f, v, data, fd)
+	case []int16:
+		return VarData(f, v, data, fd)
+	case []int32:
+		return VarData(f, v, data, fd)
+	case []float32:
+		return VarData(f, v, data, fd)
+	case []float64:
+		return VarData(f, v, data, fd)
+	case []uint16:
+		return VarData(f, v, data, fd)
+	case []uint32:
+		return VarData(f, v, data, fd)
+	case []int64:
+		return VarData(f, v, data, fd)
+	case []uint64:
+		return VarData(f, v, data, fd)
+	}
+	return fmt.Errorf("Unsupported value of type %T for variable `%s`", val.Interface(), v.Name)
+}
+
+// Data writes the values held in memory by
+// variables of f, see types.Var.Data.
+// Variables without values are left untouched.
+func Data(f *types.File, fd io.WriterAt) error {
+	for _, v := range f.Vars.Values() {
+		if v.Data.Len() == 0 {
+			continue
+		}
+		if err := VarValue(f, v, v.Data, fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// padWithFill returns val followed
+// by n fill values of variable v.
+func padWithFill(v types.Var, val types.Value, n int64) (types.Value, error) {
+	fill, err := v.FillValue()
+	if err != nil {
+		return types.Value{}, err
+	}
+	switch data := val.Interface().(type) {
+	case []byte:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]byte)[0], n))
+	case []int16:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]int16)[0], n))
+	case []int32:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]int32)[0], n))
+	case []float32:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]float32)[0], n))
+	case []float64:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]float64)[0], n))
+	case []uint16:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]uint16)[0], n))
+	case []uint32:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]uint32)[0], n))
+	case []int64:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]int64)[0], n))
+	case []uint64:
+		return types.NewValue(v.Type, appendN(data, fill.Interface().([]uint64)[0], n))
+	}
+	return val, nil
+}
+
+func appendN[T types.BaseType](data []T, x T, n int64) []T {
+	res := append([]T{}, data...)
+	for i := int64(0); i < n; i++ {
+		res = append(res, x)
+	}
+	return res
+}
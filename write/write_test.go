@@ -2,7 +2,10 @@ package write
 
 import (
 	"bytes"
+	"io"
+	"math"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/parro-it/ncdf/read"
@@ -17,19 +20,16 @@ var d = types.Dimension{
 }
 var a = types.Attr{
 	Name: "test",
-	Val:  int16(42),
-	Type: types.Short,
+	Val:  types.Shorts(42),
 }
 var v = types.Var{
 	Name: "test",
 	Attrs: types.Attrs{{
 		Name: "tst1",
-		Val:  []int16{42},
-		Type: types.Short,
+		Val:  types.Shorts(42),
 	}, {
 		Name: "tst2",
-		Val:  []int16{42},
-		Type: types.Short,
+		Val:  types.Shorts(42),
 	}}.Map(),
 
 	Dimensions: []*types.Dimension{&d, &d, &d},
@@ -44,12 +44,10 @@ var f = types.File{
 	Dimensions: []types.Dimension{d, d},
 	Attrs: types.Attrs{{
 		Name: "a1",
-		Val:  []int16{42},
-		Type: types.Short,
+		Val:  types.Shorts(42),
 	}, {
 		Name: "a2",
-		Val:  []int16{42},
-		Type: types.Short,
+		Val:  types.Shorts(42),
 	},
 	}.Map(),
 	Vars: types.Vars{{
@@ -62,12 +60,10 @@ var f = types.File{
 		Name: "v2",
 		Attrs: types.Attrs{{
 			Name: "a1",
-			Val:  []int16{42},
-			Type: types.Short,
+			Val:  types.Shorts(42),
 		}, {
 			Name: "a2",
-			Val:  []int16{42},
-			Type: types.Short,
+			Val:  types.Shorts(42),
 		}}.Map(),
 		Dimensions: []*types.Dimension{&d, &d, &d},
 		Type:       types.Short,
@@ -102,3 +98,455 @@ func TestWriteHeader(t *testing.T) {
 	assert.Equal(t, &f, f2)
 	require.NoError(t, err)
 }
+
+func TestWriteHeaderCDF5(t *testing.T) {
+	f5 := types.File{
+		Version:    types.CDF5,
+		NumRecs:    0,
+		Dimensions: []types.Dimension{d, {Name: "big", Len: 1 << 33}},
+		Attrs: types.Attrs{{
+			Name: "a1",
+			Val:  types.Shorts(42),
+		}}.Map(),
+		Vars: types.Vars{{
+			Name:       "v1",
+			Dimensions: []*types.Dimension{&d},
+			Type:       types.UInt64,
+			Size:       42 * 8,
+			Offset:     42,
+		}}.Map(),
+	}
+	f5.Vars = types.Vars{f5.Vars.Get("v1"), {
+		Name:       "v2",
+		Dimensions: []*types.Dimension{&f5.Dimensions[1]},
+		Type:       types.UByte,
+		Size:       1 << 33,
+		Offset:     42 + 42*8,
+	}}.Map()
+
+	fout, err := os.Create("/tmp/prova5.nc")
+	require.NoError(t, err)
+	err = Header(&f5, fout)
+	require.NoError(t, err)
+	require.NoError(t, fout.Close())
+
+	st, err := os.Stat(fout.Name())
+	require.NoError(t, err)
+	assert.Equal(t, int64(f5.ByteSize()), st.Size())
+
+	f2, err := read.HeaderFromDisk(fout.Name())
+	require.NoError(t, err)
+	assert.Equal(t, &f5, f2)
+}
+
+func TestWriteHeaderUnsupportedType(t *testing.T) {
+	f2 := types.File{
+		Version: types.CDF2,
+		Vars: types.Vars{{
+			Name: "v1",
+			Type: types.Int64,
+		}}.Map(),
+	}
+	var buf bytes.Buffer
+	err := Header(&f2, &buf)
+	assert.EqualError(t, err, "Type NC_INT64 of `v1` is not supported by version 2")
+}
+
+func TestWriteHeaderCDF1(t *testing.T) {
+	f1 := f
+	f1.Version = types.CDF1
+	f1.Vars = types.Vars{f.Vars.Get("v1"), f.Vars.Get("v2")}.Map()
+	f1.ComputeSizes()
+
+	var buf bytes.Buffer
+	require.NoError(t, Header(&f1, &buf))
+	assert.Equal(t, []byte{'C', 'D', 'F', 1}, buf.Bytes()[:4])
+	assert.Equal(t, int(f1.ByteSize()), buf.Len())
+
+	f2, err := read.Header(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, &f1, f2)
+	assert.Equal(t, uint64(f1.ByteSize()), f2.Vars.Get("v1").Offset)
+}
+
+func TestWriteHeaderCDF1OffsetOverflow(t *testing.T) {
+	f1 := f
+	f1.Version = types.CDF1
+	f1.Vars = types.Vars{{
+		Name:   "v1",
+		Type:   types.Short,
+		Offset: 1 << 31,
+	}}.Map()
+	var buf bytes.Buffer
+	assert.EqualError(t, Header(&f1, &buf), "Offset 2147483648 is too large for version 1")
+}
+
+func recordFile() *types.File {
+	dims := []types.Dimension{{Name: "time", Len: 0}, {Name: "x", Len: 3}}
+	f := &types.File{
+		Version:    types.CDF1,
+		Dimensions: dims,
+		Vars: types.Vars{
+			{Name: "x", Type: types.Short, Dimensions: []*types.Dimension{&dims[1]}},
+			{Name: "temp", Type: types.Short, Dimensions: []*types.Dimension{&dims[0], &dims[1]}},
+			{Name: "time", Type: types.Double, Dimensions: []*types.Dimension{&dims[0]}},
+		}.Map(),
+	}
+	return f.ComputeSizes()
+}
+
+func TestRecordVarData(t *testing.T) {
+	f := recordFile()
+	fout, err := os.Create("/tmp/records.nc")
+	require.NoError(t, err)
+	defer fout.Close()
+	require.NoError(t, Header(f, fout))
+
+	require.NoError(t, VarData(f, f.Vars.Get("x"), []int16{1, 2, 3}, fout))
+	require.NoError(t, VarData(f, f.Vars.Get("time"), []float64{0.5, 1.5}, fout))
+	assert.Equal(t, int64(2), f.NumRecs)
+	require.NoError(t, VarData(f, f.Vars.Get("temp"), []int16{10, 11, 12, 20, 21, 22}, fout))
+	assert.EqualError(t,
+		VarData(f, f.Vars.Get("temp"), []int16{10, 11}, fout),
+		"Data for record variable `temp` must contain a multiple of 3 values, got 2",
+	)
+
+	_, err = fout.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	f2, err := read.Header(fout)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), f2.NumRecs)
+
+	x, err := read.VarData[int16](f2, f2.Vars.Get("x"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{1, 2, 3}, x)
+
+	tm, err := read.VarData[float64](f2, f2.Vars.Get("time"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 1.5}, tm)
+
+	temp, err := read.VarData[int16](f2, f2.Vars.Get("temp"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{10, 11, 12, 20, 21, 22}, temp)
+}
+
+func TestStreamingNumRecs(t *testing.T) {
+	dims := []types.Dimension{{Name: "time", Len: 0}}
+	f := (&types.File{
+		Version:    types.CDF2,
+		NumRecs:    types.Streaming,
+		Dimensions: dims,
+		Vars: types.Vars{
+			{Name: "flag", Type: types.Byte, Dimensions: []*types.Dimension{&dims[0]}},
+		}.Map(),
+	}).ComputeSizes()
+
+	fout, err := os.Create("/tmp/streaming.nc")
+	require.NoError(t, err)
+	defer fout.Close()
+	require.NoError(t, Header(f, fout))
+	// single record variable: records are not padded
+	assert.Equal(t, int64(1), f.RecSize())
+	_, err = fout.Write([]byte{1, 2, 3, 4, 5})
+	require.NoError(t, err)
+
+	_, err = fout.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	f2, err := read.Header(fout)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), f2.NumRecs)
+
+	flags, err := read.VarData[byte](f2, f2.Vars.Get("flag"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5}, flags)
+}
+
+func TestStreamingGrowRecords(t *testing.T) {
+	dims := []types.Dimension{{Name: "time", Len: 0}, {Name: "x", Len: 2}}
+	f := (&types.File{
+		Version:    types.CDF2,
+		NumRecs:    types.Streaming,
+		Dimensions: dims,
+		Vars: types.Vars{
+			{Name: "x", Type: types.Int, Dimensions: []*types.Dimension{&dims[1]}},
+			{Name: "time", Type: types.Double, Dimensions: []*types.Dimension{&dims[0]}},
+		}.Map(),
+	}).ComputeSizes()
+
+	fout, err := os.Create("/tmp/streaming_grow.nc")
+	require.NoError(t, err)
+	defer fout.Close()
+	require.NoError(t, Header(f, fout))
+	require.NoError(t, VarData(f, f.Vars.Get("x"), []int32{7, 8}, fout))
+	require.NoError(t, VarData(f, f.Vars.Get("time"), []float64{0.5, 1.5}, fout))
+	assert.Equal(t, int64(2), f.NumRecs)
+
+	_, err = fout.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	f2, err := read.Header(fout)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), f2.NumRecs)
+
+	x, err := read.VarData[int32](f2, f2.Vars.Get("x"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{7, 8}, x)
+	tm, err := read.VarData[float64](f2, f2.Vars.Get("time"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 1.5}, tm)
+}
+
+func TestSlab(t *testing.T) {
+	f := recordFile()
+	fout, err := os.Create("/tmp/slab.nc")
+	require.NoError(t, err)
+	defer fout.Close()
+	require.NoError(t, Header(f, fout))
+	temp := f.Vars.Get("temp")
+
+	// second time slice first
+	require.NoError(t, Slab(f, temp, types.Slab{
+		Start: []int64{1, 0},
+		Count: []int64{1, 3},
+	}, []int16{20, 21, 22}, fout))
+	assert.Equal(t, int64(2), f.NumRecs)
+
+	// first time slice, transposed and strided
+	require.NoError(t, Slab(f, temp, types.Slab{
+		Start:  []int64{0, 0},
+		Count:  []int64{1, 2},
+		Stride: []int64{1, 2},
+		IMap:   []int64{1, 2},
+	}, []int16{10, -1, 12}, fout))
+	require.NoError(t, Slab(f, temp, types.Slab{
+		Start: []int64{0, 1},
+		Count: []int64{1, 1},
+	}, []int16{11}, fout))
+
+	assert.EqualError(t, Slab(f, temp, types.Slab{
+		Start: []int64{0, 0},
+		Count: []int64{1, 3},
+	}, []int16{11}, fout), "Slab for variable `temp` needs 3 values, got 1")
+
+	_, err = fout.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	f2, err := read.Header(fout)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), f2.NumRecs)
+
+	values, err := read.VarData[int16](f2, f2.Vars.Get("temp"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{10, 11, 12, 20, 21, 22}, values)
+}
+
+func TestWriteAttributes(t *testing.T) {
+	history := strings.Repeat("Mon Jan 31 12:00:00 2022: ncks -O in.nc out.nc\n", 20)
+	attrs := types.Attrs{
+		{Name: "valid_range", Val: types.Doubles(0, 100)},
+		{Name: "scale", Val: types.Floats(0.5)},
+		{Name: "flags", Val: types.Bytes(1, 2, 4, 8, 16)},
+		{Name: "shorts", Val: types.Shorts(-1, 2, 3)},
+		{Name: "ints", Val: types.Ints(-100000, 100000)},
+		{Name: "history", Val: types.Text(history)},
+		{Name: "empty", Val: types.Text("")},
+	}.Map()
+	f := types.File{
+		Version: types.CDF1,
+		Attrs:   attrs,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Header(&f, &buf))
+	assert.Equal(t, int(f.ByteSize()), buf.Len())
+
+	f2, err := read.Header(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, attrs.Values(), f2.Attrs.Values())
+
+	t.Run("CDF-5 types", func(t *testing.T) {
+		f := types.File{
+			Version: types.CDF5,
+			Attrs: types.Attrs{
+				{Name: "u8", Val: types.UBytes(255)},
+				{Name: "u16", Val: types.UShorts(1, 65535)},
+				{Name: "u32", Val: types.UInts(1, 2, 3)},
+				{Name: "i64", Val: types.Int64s(-1 << 40)},
+				{Name: "u64", Val: types.UInt64s(1 << 63)},
+			}.Map(),
+		}
+		var buf bytes.Buffer
+		require.NoError(t, Header(&f, &buf))
+		assert.Equal(t, int(f.ByteSize()), buf.Len())
+		f2, err := read.Header(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, f.Attrs.Values(), f2.Attrs.Values())
+	})
+
+	t.Run("typed accessors", func(t *testing.T) {
+		vr, ok := f2.Attr("valid_range")
+		require.True(t, ok)
+		assert.Equal(t, []float64{0, 100}, vr.Float64s())
+		assert.Equal(t, []int{0, 100}, vr.Ints())
+
+		h, ok := f2.Attr("history")
+		require.True(t, ok)
+		assert.Equal(t, history, h.String())
+
+		_, ok = f2.Attr("missing")
+		assert.False(t, ok)
+	})
+}
+
+func TestFill(t *testing.T) {
+	f := recordFile()
+	temp := f.Vars.Get("temp")
+	temp.Attrs = types.Attrs{{Name: "_FillValue", Val: types.Shorts(-1)}}.Map()
+	f.Vars.Set("temp", temp)
+	f.ComputeSizes()
+	fout, err := os.Create("/tmp/fill.nc")
+	require.NoError(t, err)
+	defer fout.Close()
+	require.NoError(t, Header(f, fout))
+	require.NoError(t, Fill(f, fout))
+
+	// new records of all record variables are pre-filled
+	require.NoError(t, VarData(f, f.Vars.Get("time"), []float64{0.5, 1.5}, fout))
+
+	_, err = fout.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	f2, err := read.Header(fout)
+	require.NoError(t, err)
+
+	x, err := read.VarData[int16](f2, f2.Vars.Get("x"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{types.FillShort, types.FillShort, types.FillShort}, x)
+
+	values, err := read.VarData[int16](f2, f2.Vars.Get("temp"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{-1, -1, -1, -1, -1, -1}, values)
+
+	tm, err := read.VarData[float64](f2, f2.Vars.Get("time"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 1.5}, tm)
+}
+
+func TestVarDataPacked(t *testing.T) {
+	data := []float64{-1.5, math.NaN(), 2.5}
+	p, err := types.NewPacking(types.Short, data)
+	require.NoError(t, err)
+
+	f := recordFile()
+	x := f.Vars.Get("x")
+	x.Attrs = p.Attrs().Map()
+	f.Vars.Set("x", x)
+	f.ComputeSizes()
+
+	fout, err := os.Create("/tmp/packed.nc")
+	require.NoError(t, err)
+	defer fout.Close()
+	require.NoError(t, Header(f, fout))
+	require.NoError(t, VarDataPacked(f, f.Vars.Get("x"), data, fout))
+
+	_, err = fout.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	f2, err := read.Header(fout)
+	require.NoError(t, err)
+
+	packed, err := read.VarData[int16](f2, f2.Vars.Get("x"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{-32766, types.FillShort, 32767}, packed)
+
+	values, err := read.VarDataUnpacked[float64](f2, f2.Vars.Get("x"), fout)
+	require.NoError(t, err)
+	assert.InDelta(t, -1.5, values[0], 1e-9)
+	assert.True(t, math.IsNaN(values[1]))
+	assert.InDelta(t, 2.5, values[2], 1e-9)
+
+	_, err = read.VarDataUnpacked[float32](f2, f2.Vars.Get("x"), fout)
+	assert.EqualError(t, err, "Values of variable `x` unpack to NC_DOUBLE, cannot unpack them as float32")
+}
+
+func TestData(t *testing.T) {
+	f := recordFile()
+	x := f.Vars.Get("x")
+	x.Data = types.Shorts(1, 2)
+	f.Vars.Set("x", x)
+	temp := f.Vars.Get("temp")
+	temp.Data = types.Shorts(10, 11, 12, 20)
+	f.Vars.Set("temp", temp)
+	f.NumRecs = 2
+
+	fout, err := os.Create("/tmp/data.nc")
+	require.NoError(t, err)
+	defer fout.Close()
+	require.NoError(t, Header(f, fout))
+	require.NoError(t, Fill(f, fout))
+	require.NoError(t, Data(f, fout))
+
+	_, err = fout.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	f2, err := read.Header(fout)
+	require.NoError(t, err)
+
+	xs, err := read.VarData[int16](f2, f2.Vars.Get("x"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{1, 2, types.FillShort}, xs)
+
+	temps, err := read.VarData[int16](f2, f2.Vars.Get("temp"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{10, 11, 12, 20, types.FillShort, types.FillShort}, temps)
+
+	tm, err := read.VarData[float64](f2, f2.Vars.Get("time"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{types.FillDouble, types.FillDouble}, tm)
+
+	assert.EqualError(t,
+		VarValue(f, f.Vars.Get("x"), types.Ints(1), fout),
+		"Cannot write values of type NC_INT in variable `x` of type NC_SHORT",
+	)
+	assert.EqualError(t,
+		VarValue(f, f.Vars.Get("x"), types.Shorts(1, 2, 3, 4), fout),
+		"Variable `x` has 3 values, got 4",
+	)
+}
+
+func TestVarValueUnsupported(t *testing.T) {
+	err := VarValue(recordFile(), types.Var{Name: "bad"}, types.Value{}, nil)
+	assert.EqualError(t, err, "Unsupported value of type <nil> for variable `bad`")
+}
+
+func TestVarDataChecks(t *testing.T) {
+	dims := []types.Dimension{{Name: "x", Len: 3}}
+	f := (&types.File{
+		Version:    types.CDF1,
+		Dimensions: dims,
+		Vars: types.Vars{
+			{Name: "c", Type: types.Char, Dimensions: []*types.Dimension{&dims[0]}},
+			{Name: "next", Type: types.Int, Dimensions: []*types.Dimension{&dims[0]}},
+		}.Map(),
+	}).ComputeSizes()
+	fout, err := os.Create(t.TempDir() + "/checks.nc")
+	require.NoError(t, err)
+	defer fout.Close()
+	require.NoError(t, Header(f, fout))
+	require.NoError(t, Fill(f, fout))
+	c := f.Vars.Get("c")
+
+	assert.EqualError(t,
+		VarData(f, c, []float64{1, 2, 3}, fout),
+		"Cannot write values of type float64 in variable `c` of type NC_CHAR",
+	)
+	assert.EqualError(t,
+		VarData(f, c, []byte("abcdefgh"), fout),
+		"Variable `c` has 3 values, got 8",
+	)
+	assert.EqualError(t,
+		Slab(f, c, types.Slab{Start: []int64{0}, Count: []int64{1}}, []int32{1}, fout),
+		"Cannot write values of type int32 in variable `c` of type NC_CHAR",
+	)
+	require.NoError(t, VarData(f, c, []byte("abc"), fout))
+
+	// values of the next variable are untouched
+	next, err := read.VarData[int32](f, f.Vars.Get("next"), fout)
+	require.NoError(t, err)
+	assert.Equal(t, []int32{types.FillInt, types.FillInt, types.FillInt}, next)
+}